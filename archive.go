@@ -0,0 +1,353 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the type of archive written by WriteArchive
+type ArchiveFormat int
+
+// Archive formats
+const (
+	ArchiveZip ArchiveFormat = iota
+	ArchiveTarGz
+)
+
+// WriteArchive walks the file, or the folder and everything in it, and streams it to w as
+// an archive in the given format.  Nothing is written to the local disk, so the archive can
+// be served directly from an http handler.  Entries are named relative to the folder, and
+// keep the modified times of the files on the freehold instance.
+// Any error stops the archive from being written, leaving w with a partial archive
+func (f *File) WriteArchive(w io.Writer, format ArchiveFormat) error {
+	var aw archiveWriter
+	switch format {
+	case ArchiveZip:
+		aw = &zipArchiveWriter{zip.NewWriter(w)}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		aw = &tarArchiveWriter{gz: gz, tw: tar.NewWriter(gz)}
+	default:
+		return fmt.Errorf("Invalid archive format %d", format)
+	}
+
+	root, err := ParsePath(f.URL)
+	if err != nil {
+		return err
+	}
+	base := root
+	if !f.IsDir {
+		// a single file is archived under its own name
+		base = root.Parent()
+	}
+
+	err = f.client.WalkWithOptions(f.URL, &WalkOptions{Sorted: true}, func(p string, prop *Property, err error) error {
+		if err != nil {
+			return err
+		}
+		fhPath, err := ParsePath(p)
+		if err != nil {
+			return err
+		}
+		name, err := base.Rel(fhPath)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			return nil
+		}
+
+		if prop.IsDir {
+			return aw.writeDir(name+"/", prop)
+		}
+
+		file := &File{*prop}
+		defer file.Close()
+		return aw.writeFile(name, file)
+	})
+	if err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+type archiveWriter interface {
+	writeDir(name string, prop *Property) error
+	writeFile(name string, file *File) error
+	Close() error
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipArchiveWriter) header(name string, prop *Property) (*zip.FileHeader, error) {
+	info, err := newFileInfo(prop)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = name
+	if !prop.IsDir {
+		hdr.Method = zip.Deflate
+	}
+	return hdr, nil
+}
+
+func (z *zipArchiveWriter) writeDir(name string, prop *Property) error {
+	hdr, err := z.header(name, prop)
+	if err != nil {
+		return err
+	}
+	_, err = z.zw.CreateHeader(hdr)
+	return err
+}
+
+func (z *zipArchiveWriter) writeFile(name string, file *File) error {
+	hdr, err := z.header(name, &file.Property)
+	if err != nil {
+		return err
+	}
+	w, err := z.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (z *zipArchiveWriter) Close() error {
+	return z.zw.Close()
+}
+
+type tarArchiveWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (t *tarArchiveWriter) writeDir(name string, prop *Property) error {
+	hdr, err := tar.FileInfoHeader(&fileInfo{prop: prop}, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	return t.tw.WriteHeader(hdr)
+}
+
+func (t *tarArchiveWriter) writeFile(name string, file *File) error {
+	info, err := newFileInfo(&file.Property)
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+
+	err = t.tw.WriteHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(t.tw, file)
+	return err
+}
+
+func (t *tarArchiveWriter) Close() error {
+	err := t.tw.Close()
+	if err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
+
+// ExtractArchive unpacks a zip, tar, or tar.gz archive into the dest folder on the freehold
+// instance, creating dest and any folders in the archive as needed.  The format is detected
+// from the data.  Zip archives are spooled locally first, as they can't be read as a stream.
+// Files keep the modified times recorded in the archive, and the client's OnConflict strategy
+// applies to files which already exist.
+// A failure on any individual entry doesn't stop the rest of the archive from being extracted,
+// and all failures are returned together as PathErrors
+func (c *Client) ExtractArchive(r io.Reader, dest string) error {
+	destPath, err := ParsePath(dest)
+	if err != nil || destPath.Kind != KindFile {
+		return errors.New("Invalid folder path")
+	}
+
+	err = c.MkdirAll(destPath.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	x := &extractor{
+		client:  c,
+		dest:    destPath,
+		folders: make(map[string]*File),
+	}
+
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		err = x.zip(br)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, gErr := gzip.NewReader(br)
+		if gErr != nil {
+			return gErr
+		}
+		err = x.tar(gz)
+	default:
+		err = x.tar(br)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(x.errs) != 0 {
+		return x.errs
+	}
+	return nil
+}
+
+type extractor struct {
+	client  *Client
+	dest    Path
+	folders map[string]*File
+	errs    PathErrors
+}
+
+// target returns the freehold path for an archive entry.  Entries can't be extracted
+// outside of the destination folder
+func (x *extractor) target(name string) (Path, bool) {
+	name = strings.Replace(name, "\\", "/", -1)
+	for _, s := range strings.Split(name, "/") {
+		if s == ".." {
+			return Path{}, false
+		}
+	}
+	p := x.dest.Join(name)
+	if !x.dest.Contains(p) || p == x.dest {
+		return Path{}, false
+	}
+	return p, true
+}
+
+// folder returns the folder, creating it if it doesn't exist
+func (x *extractor) folder(p Path) (*File, error) {
+	key := p.String()
+	if dir, ok := x.folders[key]; ok {
+		return dir, nil
+	}
+	err := x.client.MkdirAll(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := x.client.GetFile(key)
+	if err != nil {
+		return nil, err
+	}
+	x.folders[key] = dir
+	return dir, nil
+}
+
+func (x *extractor) extract(name string, isDir bool, r io.Reader, size int64, modTime time.Time) {
+	p, ok := x.target(name)
+	if !ok {
+		if strings.Trim(name, "/.") != "" {
+			x.errs = append(x.errs, &PathError{Path: name, Err: errors.New("Invalid archive entry path")})
+		}
+		return
+	}
+
+	if isDir {
+		_, err := x.folder(p)
+		if err != nil {
+			x.errs = append(x.errs, &PathError{Path: name, Err: err})
+		}
+		return
+	}
+
+	parent, err := x.folder(p.Parent())
+	if err != nil {
+		x.errs = append(x.errs, &PathError{Path: name, Err: err})
+		return
+	}
+	_, err = x.client.UploadFromReader(p.Name, r, size, modTime, parent)
+	if err != nil {
+		x.errs = append(x.errs, &PathError{Path: name, Err: err})
+	}
+}
+
+func (x *extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			x.extract(hdr.Name, true, nil, 0, hdr.ModTime)
+		case tar.TypeReg:
+			x.extract(hdr.Name, false, tr, hdr.Size, hdr.ModTime)
+		}
+	}
+}
+
+func (x *extractor) zip(r io.Reader) error {
+	s := &spool{threshold: SpoolThreshold}
+	defer s.cleanup()
+
+	_, err := io.Copy(s, r)
+	if err != nil {
+		return err
+	}
+	data, err := s.reader()
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(data.(io.ReaderAt), s.size)
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			x.extract(zf.Name, true, nil, 0, zf.Modified)
+			continue
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			x.errs = append(x.errs, &PathError{Path: zf.Name, Err: err})
+			continue
+		}
+		x.extract(zf.Name, false, rc, int64(zf.UncompressedSize64), zf.Modified)
+		rc.Close()
+	}
+	return nil
+}
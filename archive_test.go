@@ -0,0 +1,192 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	m.addDir("/v1/file/testing")
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/a.txt", "file a", modified)
+	m.addFile("/v1/file/testing/sub/b.txt", "file b", modified.Add(time.Hour))
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	folder, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"a.txt": "file a", "sub/": "", "sub/b.txt": "file b"}
+	times := map[string]time.Time{"a.txt": modified, "sub/b.txt": modified.Add(time.Hour)}
+
+	var zipped bytes.Buffer
+	err = folder.WriteArchive(&zipped, ArchiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(expected) {
+		t.Errorf("Expected %d zip entries got %d", len(expected), len(zr.File))
+	}
+	for _, zf := range zr.File {
+		data, ok := expected[zf.Name]
+		if !ok {
+			t.Errorf("Unexpected zip entry %s", zf.Name)
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result) != data {
+			t.Errorf("Zip entry %s doesn't match. Got %q", zf.Name, result)
+		}
+		if mod, ok := times[zf.Name]; ok && !zf.Modified.Equal(mod) {
+			t.Errorf("Zip entry %s modified time expected %s got %s", zf.Name, mod, zf.Modified)
+		}
+	}
+
+	var tarred bytes.Buffer
+	err = folder.WriteArchive(&tarred, ArchiveTarGz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(tarred.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	count := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+		result, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result) != expected[hdr.Name] {
+			t.Errorf("Tar entry %s doesn't match. Got %q", hdr.Name, result)
+		}
+		if mod, ok := times[hdr.Name]; ok && !hdr.ModTime.Equal(mod) {
+			t.Errorf("Tar entry %s modified time expected %s got %s", hdr.Name, mod, hdr.ModTime)
+		}
+	}
+	if count != len(expected) {
+		t.Errorf("Expected %d tar entries got %d", len(expected), count)
+	}
+
+	// archives extract back to the same tree
+	for dest, archive := range map[string][]byte{
+		"/v1/file/fromzip":      zipped.Bytes(),
+		"/v1/file/fromtar/deep": tarred.Bytes(),
+	} {
+		err = client.ExtractArchive(bytes.NewReader(archive), dest)
+		if err != nil {
+			t.Fatalf("Error extracting to %s: %s", dest, err)
+		}
+		for name, data := range expected {
+			n := m.node(dest + "/" + name)
+			if n == nil {
+				t.Errorf("%s was not extracted to %s", name, dest)
+				continue
+			}
+			if string(n.data) != data {
+				t.Errorf("Extracted %s doesn't match. Got %q", name, n.data)
+			}
+			if mod, ok := times[name]; ok && !n.modified.Equal(mod) {
+				t.Errorf("Extracted %s modified time expected %s got %s", name, mod, n.modified)
+			}
+		}
+	}
+
+	// a single file is archived under its own name
+	file, err := client.GetFile("/v1/file/testing/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipped.Reset()
+	err = file.WriteArchive(&zipped, ArchiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err = zip.NewReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "a.txt" {
+		t.Errorf("Single file archive incorrect")
+	}
+}
+
+func TestExtractArchiveInvalidPaths(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"../escape.txt", "ok.txt"} {
+		err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 4, Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.ExtractArchive(&buf, "/v1/file/testing")
+	errs, ok := err.(PathErrors)
+	if !ok || len(errs) != 1 || errs[0].Path != "../escape.txt" {
+		t.Fatalf("Expected a path error for the escaping entry got %v", err)
+	}
+	if m.node("/v1/file/escape.txt") != nil {
+		t.Errorf("Entry was extracted outside of the destination")
+	}
+	if m.node("/v1/file/testing/ok.txt") == nil {
+		t.Errorf("Valid entry was not extracted")
+	}
+}
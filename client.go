@@ -28,10 +28,33 @@ import (
 // instead use a Security Token generated for this specific
 // client
 type Client struct {
+	// OnConflict is the strategy used when a file is uploaded with the same name as an
+	// existing file.  It applies to UploadFile, UploadFromReader, and everything built on
+	// them, such as CopyTo and Sync.  Defaults to ConflictFail
+	OnConflict ConflictStrategy
+
+	// InheritPermissions sets the permissions of newly created files, folders, and datastores
+	// to those of the folder they are created in, instead of leaving them private.  The owner
+	// is never inherited
+	InheritPermissions bool
+
+	// PermissionRules override the permissions of newly created files, folders, and datastores
+	// with matching paths.  Rules are checked in order, and apply whether or not
+	// InheritPermissions is set.  Every rule is validated before an item is created, and an
+	// item whose inherited or rule based permissions can't be set is removed again
+	PermissionRules []PermissionRule
+
+	// UseTrash moves deleted files and folders into the user's hidden trash folder instead of
+	// deleting them permanently, so they can be restored with Trash.  Datastores are always
+	// deleted permanently
+	UseTrash bool
+
 	hClient  *http.Client
 	root     *url.URL
 	username string
 	pass     string
+
+	transforms []Transform
 }
 
 //jsend is the reponse format from a freehold instance
@@ -73,9 +96,9 @@ func NewFromClient(client *http.Client, rootURL, username, passwordOrToken strin
 // the passed in result interface.  Only to be used with
 // JSEND responses
 func (c *Client) doRequest(method, fhPath string, send interface{}, result interface{}) error {
-	c.root.Path = fhPath
+	uri := c.fullURL(fhPath)
 
-	req, err := http.NewRequest(method, c.root.String(), nil)
+	req, err := http.NewRequest(method, uri, nil)
 
 	if err != nil {
 		return err
@@ -107,7 +130,7 @@ func (c *Client) doRequest(method, fhPath string, send interface{}, result inter
 		return err
 	}
 
-	err = isError(c.root.String(), res.StatusCode, response)
+	err = isError(uri, res.StatusCode, response)
 	if err != nil {
 		return err
 	}
@@ -121,6 +144,18 @@ func (c *Client) doRequest(method, fhPath string, send interface{}, result inter
 	return nil
 }
 
+// fullURL returns the full url for the passed in freehold path
+// without modifying the client's shared root url, so requests can be
+// safely run concurrently.  fhPath is unescaped, and is percent encoded
+// as needed, so names containing characters such as #, ?, % or spaces
+// are requested correctly
+func (c *Client) fullURL(fhPath string) string {
+	u := *c.root
+	u.Path = fhPath
+	u.RawPath = ""
+	return u.String()
+}
+
 // RootURL returns the Root of this freehold client
 // I.E. the domain + port that all requests will be made with
 func (c *Client) RootURL() *url.URL {
@@ -0,0 +1,122 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// CopyOptions are the options used when copying with CopyTo
+type CopyOptions struct {
+	// Permissions sets the permissions of each new file and folder to match
+	// the permissions of the one it was copied from
+	Permissions bool
+}
+
+// CopyTo copies the file, or the folder and everything in it, to dest on the freehold
+// instance.  dest is the full path of the new copy, and its parent folder must already
+// exist.  File data is streamed from the source straight into the new copy without
+// touching the local disk, and modified times are preserved.
+// A failure on any individual file or folder doesn't stop the copy of the rest of the
+// tree, and all failures are returned together as PathErrors
+func (f *File) CopyTo(dest string, opts *CopyOptions) error {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+	destPath, err := ParsePath(dest)
+	if err != nil || destPath.Kind != KindFile || destPath.IsRoot() {
+		return errors.New("Invalid file path")
+	}
+	rootPath, err := ParsePath(f.URL)
+	if err != nil {
+		return err
+	}
+	if rootPath.Contains(destPath) {
+		return errors.New("Cannot copy a folder into itself.")
+	}
+
+	root := strings.TrimSuffix(f.URL, "/")
+	dest = destPath.String()
+
+	c := f.client
+	folders := make(map[string]*File)
+	var errs PathErrors
+
+	// folder returns the destination folder, retrieving it if it hasn't been
+	// created during this copy
+	folder := func(p string) (*File, error) {
+		if dir, ok := folders[p]; ok {
+			return dir, nil
+		}
+		dir, err := c.GetFile(p)
+		if err != nil {
+			return nil, err
+		}
+		folders[p] = dir
+		return dir, nil
+	}
+
+	err = c.Walk(root, func(p string, prop *Property, err error) error {
+		if err != nil {
+			errs = append(errs, &PathError{Path: p, Err: err})
+			return nil
+		}
+
+		target := dest + strings.TrimPrefix(p, root)
+
+		var copied *Property
+		if prop.IsDir {
+			err = c.NewFolder(target + "/")
+			if err == nil {
+				var dir *File
+				dir, err = folder(target)
+				if err == nil {
+					copied = &dir.Property
+				}
+			}
+			if err != nil {
+				errs = append(errs, &PathError{Path: p, Err: err})
+				return SkipDir
+			}
+		} else {
+			parent, err := folder(path.Dir(target))
+			if err != nil {
+				errs = append(errs, &PathError{Path: p, Err: err})
+				return nil
+			}
+			src := &File{*prop}
+			size, err := src.DataSize()
+			if err != nil {
+				errs = append(errs, &PathError{Path: p, Err: err})
+				return nil
+			}
+			newFile, err := c.UploadFromReader(path.Base(target), src, size, src.ModifiedTime(), parent)
+			src.Close()
+			if err != nil {
+				errs = append(errs, &PathError{Path: p, Err: err})
+				return nil
+			}
+			copied = &newFile.Property
+		}
+
+		if opts.Permissions && prop.Permissions != nil {
+			err = copied.SetPermission(prop.Permissions.withoutOwner())
+			if err != nil {
+				errs = append(errs, &PathError{Path: p, Err: err})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
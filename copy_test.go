@@ -0,0 +1,80 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCopyTo(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/test.txt", "test file", modified)
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/sub/sub.txt", "sub file", modified)
+	m.node("/v1/file/testing/sub/sub.txt").perm = &Permission{Owner: username, Private: "rw", Public: "r"}
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.CopyTo("/v1/file/testing/sub/copy", nil)
+	if err == nil {
+		t.Errorf("Copying a folder into itself did not fail")
+	}
+
+	err = f.CopyTo("/v1/file/copy", &CopyOptions{Permissions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, expected := range map[string]string{
+		"/v1/file/copy/test.txt":    "test file",
+		"/v1/file/copy/sub/sub.txt": "sub file",
+	} {
+		n := m.node(p)
+		if n == nil {
+			t.Errorf("%s was not copied", p)
+			continue
+		}
+		if string(n.data) != expected {
+			t.Errorf("Copy of %s doesn't match. Expected %s got %s", p, expected, n.data)
+		}
+		if !n.modified.Equal(modified) {
+			t.Errorf("Modified time of %s was not preserved. Got %v", p, n.modified)
+		}
+	}
+
+	n := m.node("/v1/file/copy/sub/sub.txt")
+	if n != nil && (n.perm == nil || n.perm.Public != "r") {
+		t.Errorf("Permissions were not copied. Got %v", n.perm)
+	}
+
+	if m.node("/v1/file/testing/test.txt") == nil {
+		t.Errorf("Source file was removed")
+	}
+
+	single, err := client.GetFile("/v1/file/testing/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = single.CopyTo("/v1/file/testing/test copy.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := m.node("/v1/file/testing/test copy.txt"); n == nil || string(n.data) != "test file" {
+		t.Errorf("Single file was not copied")
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"errors"
 	"os"
 	"path"
-	"strings"
 )
 
 // Datastore is a datastore stored on freehold instance
@@ -51,24 +50,25 @@ func (kv *KeyValue) Value(result interface{}) error {
 
 // GetDatastore retrieves a datastore for reading or writing from a freehold instance
 func (c *Client) GetDatastore(filePath string) (*Datastore, error) {
-	filePath = strings.TrimSuffix(filePath, "/")
-	propPath := propertyPath(filePath)
-
-	d := &Datastore{Property{}}
-	err := c.doRequest("GET", propPath, nil, d)
-
+	prop, err := c.Stat(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	d.client = c
-
-	return d, nil
+	return &Datastore{*prop}, nil
 }
 
 // NewDatastore creates a new datastore file at the path, passed in
 func (c *Client) NewDatastore(filePath string) (*Datastore, error) {
-	err := c.doRequest("POST", filePath, nil, nil)
+	prm, err := c.newPermission(filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = c.doRequest("POST", filePath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = applyNewPermission(&Property{URL: filePath, client: c}, prm)
 	if err != nil {
 		return nil, err
 	}
@@ -97,11 +97,21 @@ func (c *Client) UploadDatastore(dsFile *os.File, dest *File) (*Datastore, error
 		},
 	}
 
+	prm, err := c.newPermission(d.URL, &dest.Property)
+	if err != nil {
+		return nil, err
+	}
+
 	err = d.upload("POST", dsFile, info.Size(), info.ModTime())
 	if err != nil {
 		return nil, err
 	}
 
+	err = applyNewPermission(&d.Property, prm)
+	if err != nil {
+		return nil, err
+	}
+
 	return c.GetDatastore(d.URL)
 }
 
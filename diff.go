@@ -0,0 +1,289 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DiffOptions are the options used by Diff
+type DiffOptions struct {
+	// Hash compares the contents of files which are the same size, instead of their modified
+	// times.  Remote files are downloaded to be hashed
+	Hash bool
+	// Permissions reports files whose local permissions don't match their remote permissions.
+	// Freehold's private, friend, and public permissions are compared to the owner, group,
+	// and other permission bits respectively, the same as in FS
+	Permissions bool
+}
+
+// DiffChange is the type of difference between a local and remote path
+type DiffChange int
+
+// Types of differences
+const (
+	DiffAdded   DiffChange = iota // path exists locally, but not on the freehold instance
+	DiffRemoved                   // path exists on the freehold instance, but not locally
+	DiffChanged                   // path exists in both, but is different
+)
+
+func (c DiffChange) String() string {
+	switch c {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// MarshalText writes the change by name, for JSON output
+func (c DiffChange) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// DiffFile is the state of a path on one side of a diff
+type DiffFile struct {
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	IsDir    bool      `json:"isDir,omitempty"`
+	Mode     string    `json:"mode,omitempty"`
+	Hash     string    `json:"hash,omitempty"`
+}
+
+// DiffEntry is a single difference between a local directory and a freehold folder.  Paths
+// are slash separated and relative to the root of the diff
+type DiffEntry struct {
+	Change DiffChange `json:"change"`
+	Path   string     `json:"path"`
+	// Reasons lists why a changed path is different: type, size, modified, content,
+	// or permissions
+	Reasons []string  `json:"reasons,omitempty"`
+	Local   *DiffFile `json:"local,omitempty"`
+	Remote  *DiffFile `json:"remote,omitempty"`
+}
+
+// Diff is the list of differences between a local directory and a freehold folder,
+// from the point of view of uploading the local directory.  It can be marshalled
+// to JSON for structured output
+type Diff struct {
+	Local   string       `json:"local"`
+	Remote  string       `json:"remote"`
+	Entries []*DiffEntry `json:"entries"`
+}
+
+// String returns the diff in a unified, human readable form, with one line per path
+// prefixed by + for added, - for removed, and ~ for changed
+func (d *Diff) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", d.Remote, d.Local)
+
+	for _, e := range d.Entries {
+		name := e.Path
+		if (e.Local != nil && e.Local.IsDir) || (e.Local == nil && e.Remote.IsDir) {
+			name += "/"
+		}
+		switch e.Change {
+		case DiffAdded:
+			fmt.Fprintf(&b, "+ %s\n", name)
+		case DiffRemoved:
+			fmt.Fprintf(&b, "- %s\n", name)
+		case DiffChanged:
+			var details []string
+			for _, r := range e.Reasons {
+				switch r {
+				case "size":
+					details = append(details, fmt.Sprintf("size %d -> %d", e.Remote.Size, e.Local.Size))
+				case "modified":
+					details = append(details, fmt.Sprintf("modified %s -> %s",
+						e.Remote.Modified.Format(time.RFC3339), e.Local.Modified.Format(time.RFC3339)))
+				case "permissions":
+					details = append(details, fmt.Sprintf("mode %s -> %s", e.Remote.Mode, e.Local.Mode))
+				default:
+					details = append(details, r)
+				}
+			}
+			fmt.Fprintf(&b, "~ %s (%s)\n", name, strings.Join(details, ", "))
+		}
+	}
+	return b.String()
+}
+
+// Diff compares the local directory to the freehold folder, and reports the paths which have
+// been added, removed, or changed locally.  Files are compared by size and modified time, or by
+// size and content if opts.Hash is set.  If the freehold folder doesn't exist, everything in the
+// local directory is reported as added
+func (c *Client) Diff(localDir, remotePath string, opts *DiffOptions) (*Diff, error) {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+
+	root := strings.TrimSuffix(remotePath, "/")
+	d := &Diff{Local: localDir, Remote: root}
+
+	local := make(map[string]*DiffFile)
+	localPaths := make(map[string]string)
+	err := filepath.Walk(localDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == localDir || !(info.IsDir() || info.Mode().IsRegular()) {
+			return nil
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		local[rel] = &DiffFile{
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+			IsDir:    info.IsDir(),
+			Mode:     info.Mode().Perm().String(),
+		}
+		if info.IsDir() {
+			local[rel].Size = 0
+		}
+		localPaths[rel] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	remote := make(map[string]*DiffFile)
+	remoteProps := make(map[string]*Property)
+	err = c.Walk(root, func(p string, prop *Property, err error) error {
+		if err != nil {
+			if prop == nil && IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		if rel == "" {
+			return nil
+		}
+		size, err := prop.DataSize()
+		if err != nil {
+			return &PathError{Path: rel, Err: err}
+		}
+		remote[rel] = &DiffFile{
+			Size:     size,
+			Modified: prop.ModifiedTime(),
+			IsDir:    prop.IsDir,
+			Mode:     permissionMode(prop.Permissions, prop.IsDir).Perm().String(),
+		}
+		remoteProps[rel] = prop
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for rel, l := range local {
+		r, ok := remote[rel]
+		if !ok {
+			d.Entries = append(d.Entries, &DiffEntry{Change: DiffAdded, Path: rel, Local: l})
+			continue
+		}
+
+		reasons, err := compareDiffFiles(l, r, localPaths[rel], remoteProps[rel], opts)
+		if err != nil {
+			return nil, &PathError{Path: rel, Err: err}
+		}
+		if len(reasons) != 0 {
+			d.Entries = append(d.Entries, &DiffEntry{Change: DiffChanged, Path: rel, Reasons: reasons,
+				Local: l, Remote: r})
+		}
+	}
+	for rel, r := range remote {
+		if _, ok := local[rel]; !ok {
+			d.Entries = append(d.Entries, &DiffEntry{Change: DiffRemoved, Path: rel, Remote: r})
+		}
+	}
+
+	sort.Slice(d.Entries, func(i, j int) bool {
+		return d.Entries[i].Path < d.Entries[j].Path
+	})
+	return d, nil
+}
+
+// compareDiffFiles returns the reasons the local and remote paths are different, if any
+func compareDiffFiles(local, remote *DiffFile, localPath string, prop *Property, opts *DiffOptions) ([]string, error) {
+	if local.IsDir != remote.IsDir {
+		return []string{"type"}, nil
+	}
+
+	var reasons []string
+	if !local.IsDir {
+		if opts.Hash && local.Size == remote.Size {
+			var err error
+			local.Hash, err = hashLocal(localPath)
+			if err != nil {
+				return nil, err
+			}
+			remote.Hash, err = hashRemote(&File{*prop})
+			if err != nil {
+				return nil, err
+			}
+			if local.Hash != remote.Hash {
+				reasons = append(reasons, "content")
+			}
+		} else {
+			if local.Size != remote.Size {
+				reasons = append(reasons, "size")
+			}
+			if !local.Modified.Truncate(time.Second).Equal(remote.Modified.Truncate(time.Second)) {
+				reasons = append(reasons, "modified")
+			}
+		}
+	}
+
+	if opts.Permissions && local.Mode != remote.Mode {
+		// freehold has no execute permission, so it's only compared on folders
+		if local.IsDir || localModeWithoutExec(local.Mode) != remote.Mode {
+			reasons = append(reasons, "permissions")
+		}
+	}
+	return reasons, nil
+}
+
+func localModeWithoutExec(mode string) string {
+	return strings.Replace(mode, "x", "-", -1)
+}
+
+func hashLocal(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashReader(f)
+}
+
+func hashRemote(f *File) (string, error) {
+	defer f.Close()
+	return hashReader(f)
+}
+
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/same.txt", "same", modified)
+	m.addFile("/v1/file/testing/size.txt", "short", modified)
+	m.addFile("/v1/file/testing/touched.txt", "touched", modified.Add(-time.Hour))
+	m.addFile("/v1/file/testing/edited.txt", "abcd", modified)
+	m.addFile("/v1/file/testing/removed.txt", "removed", modified)
+	m.addFile("/v1/file/testing/sub/perm.txt", "perm", modified)
+	m.node("/v1/file/testing/sub/perm.txt").perm = &Permission{Private: "rw", Public: "r"}
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "freeholdclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeLocal := func(name, data string) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		err := ioutil.WriteFile(p, []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(p, modified, modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLocal("same.txt", "same")
+	writeLocal("size.txt", "longer")
+	writeLocal("touched.txt", "touched")
+	writeLocal("edited.txt", "wxyz")
+	writeLocal("added.txt", "added")
+	writeLocal("sub/perm.txt", "perm")
+	os.Chmod(filepath.Join(dir, "sub"), 0755)
+
+	changes := func(d *Diff) map[string]string {
+		result := make(map[string]string)
+		for _, e := range d.Entries {
+			result[e.Path] = e.Change.String() + " " + strings.Join(e.Reasons, ",")
+		}
+		return result
+	}
+
+	d, err := client.Diff(dir, dirPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"added.txt":   "added ",
+		"removed.txt": "removed ",
+		"size.txt":    "changed size",
+		"touched.txt": "changed modified",
+	}
+	if got := changes(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Diff doesn't match. Expected %v got %v", expected, got)
+	}
+
+	text := d.String()
+	for _, line := range []string{"+ added.txt\n", "- removed.txt\n", "~ size.txt (size 5 -> 6)\n"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("Diff text doesn't contain %q:\n%s", line, text)
+		}
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"change":"added"`) {
+		t.Errorf("Diff json doesn't contain change names: %s", data)
+	}
+
+	d, err = client.Diff(dir, dirPath, &DiffOptions{Hash: true, Permissions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string]string{
+		"added.txt":    "added ",
+		"removed.txt":  "removed ",
+		"size.txt":     "changed size",
+		"edited.txt":   "changed content",
+		"sub":          "changed permissions",
+		"sub/perm.txt": "changed permissions",
+	}
+	if got := changes(d); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Hashed diff doesn't match. Expected %v got %v", expected, got)
+	}
+
+	// everything is added to a folder which doesn't exist yet
+	d, err = client.Diff(dir, "/v1/file/missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Entries) != 7 {
+		t.Errorf("Expected 7 added paths got %d", len(d.Entries))
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DownloadOptions are the options used when downloading a folder with DownloadDir
+type DownloadOptions struct {
+	// Concurrency is the number of files downloaded at the same time
+	// defaults to 4
+	Concurrency int
+}
+
+// DownloadDir downloads the contents of the folder and all of its sub folders
+// into the local directory, recreating the folder hierarchy.  Files which already
+// exist locally with the same size and modified time are skipped.  Each file is
+// downloaded to a temp file next to it, and only replaces the local file once the
+// download is complete.  Files and folders whose names can't be used as a local
+// name, such as .. or names containing a path separator, aren't downloaded.
+// A failure on any individual file or folder doesn't stop the download of the rest
+// of the tree, and all failures are returned together as PathErrors
+func (f *File) DownloadDir(localDir string, opts *DownloadOptions) error {
+	if !f.IsDir {
+		return errors.New("File is not a directory.")
+	}
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	d := &downloader{
+		files: make(chan *downloadJob),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range d.files {
+				err := downloadFile(job.file, job.localPath)
+				if err != nil {
+					d.fail(job.file.URL, err)
+				}
+			}
+		}()
+	}
+
+	root := strings.TrimSuffix(f.URL, "/")
+	f.client.Walk(root, func(p string, prop *Property, err error) error {
+		if err != nil {
+			d.fail(p, err)
+			return nil
+		}
+
+		if p != root && (!validLocalName(prop.Name) || !strings.HasPrefix(p, root+"/")) {
+			d.fail(p, fmt.Errorf("%q is not a valid local file name", prop.Name))
+			if prop.IsDir {
+				return SkipDir
+			}
+			return nil
+		}
+
+		localPath := filepath.Join(localDir, filepath.FromSlash(strings.TrimPrefix(p, root)))
+		if prop.IsDir {
+			err = os.MkdirAll(localPath, 0755)
+			if err != nil {
+				d.fail(p, err)
+				return SkipDir
+			}
+			return nil
+		}
+
+		file := &File{*prop}
+		if !localMatches(file, localPath) {
+			d.files <- &downloadJob{file: file, localPath: localPath}
+		}
+		return nil
+	})
+	close(d.files)
+	wg.Wait()
+
+	if len(d.errs) != 0 {
+		return d.errs
+	}
+	return nil
+}
+
+type downloadJob struct {
+	file      *File
+	localPath string
+}
+
+type downloader struct {
+	files chan *downloadJob
+
+	sync.Mutex
+	errs PathErrors
+}
+
+func (d *downloader) fail(path string, err error) {
+	d.Lock()
+	d.errs = append(d.errs, &PathError{Path: path, Err: err})
+	d.Unlock()
+}
+
+// validLocalName is whether or not the name of a remote file or folder can be used as
+// a single element of a local path
+func validLocalName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsRune(name, '/') && !strings.ContainsRune(name, filepath.Separator)
+}
+
+// localMatches is whether or not the local file already matches the size and
+// modified time of the remote file
+func localMatches(f *File, localPath string) bool {
+	info, err := os.Stat(localPath)
+	if err != nil || info.IsDir() ||
+		!info.ModTime().Truncate(time.Second).Equal(f.ModifiedTime().Truncate(time.Second)) {
+		return false
+	}
+
+	size, err := f.DataSize()
+	return err == nil && info.Size() == size
+}
+
+// downloadFile writes the remote file to a temp file in the same local folder, sets its
+// modified time to match the remote file's, and then renames it over the local path, so
+// an existing local file is left untouched if the download fails
+func downloadFile(f *File, localPath string) error {
+	defer f.Close()
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(localPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	local, err := ioutil.TempFile(filepath.Dir(localPath), "."+filepath.Base(localPath)+".fhtmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := local.Name()
+
+	_, err = io.Copy(local, f)
+	if cErr := local.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, mode)
+	}
+	if modTime := f.ModifiedTime(); err == nil && !modTime.IsZero() {
+		err = os.Chtimes(tmpPath, modTime, modTime)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, localPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
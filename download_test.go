@@ -0,0 +1,184 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadDir(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/test.txt", "test file", modified)
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/sub/sub.txt", "sub file", modified)
+	m.addDir("/v1/file/testing/empty")
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "freeholdclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.DownloadDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"test.txt":    "test file",
+		"sub/sub.txt": "sub file",
+	} {
+		localPath := filepath.Join(dir, filepath.FromSlash(name))
+		data, err := ioutil.ReadFile(localPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Errorf("File %s contents don't match. Expected %s got %s", name, expected, data)
+		}
+		info, err := os.Stat(localPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(modified) {
+			t.Errorf("File %s modified time doesn't match. Expected %v got %v", name, modified, info.ModTime())
+		}
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, "empty")); err != nil || !info.IsDir() {
+		t.Errorf("Empty folder was not created: %v", err)
+	}
+
+	// matching files are skipped
+	m.addFile("/v1/file/testing/test.txt", "changed!!", modified)
+	err = f.DownloadDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "test.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "test file" {
+		t.Errorf("Unchanged file was downloaded again. Got %s", data)
+	}
+
+	// failures don't stop the rest of the tree
+	m.addFile("/v1/file/testing/sub/sub.txt", "sub file changed", modified)
+	m.addFile("/v1/file/testing/test.txt", "test file changed", modified)
+	os.Mkdir(filepath.Join(dir, "blocked"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "blocked", "file.txt"), nil, 0644)
+	m.addFile("/v1/file/testing/blocked", "not a dir", modified)
+
+	err = f.DownloadDir(dir, nil)
+	errs, ok := err.(PathErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("Expected one PathError, got %v", err)
+	}
+	if errs[0].Path != "/v1/file/testing/blocked" {
+		t.Errorf("Expected failure on /v1/file/testing/blocked got %s", errs[0].Path)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "sub", "sub.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "sub file changed" {
+		t.Errorf("Changed file was not downloaded. Got %s", data)
+	}
+}
+
+func TestDownloadDirUnsafe(t *testing.T) {
+	startMockServer()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC).Format(time.RFC3339)
+	mux.HandleFunc("/v1/properties/file/testing", func(w http.ResponseWriter, r *http.Request) {
+		mockRespond(w, http.StatusOK, &Property{Name: "testing", URL: "/v1/file/testing/", IsDir: true})
+	})
+	mux.HandleFunc("/v1/properties/file/testing/", func(w http.ResponseWriter, r *http.Request) {
+		mockRespond(w, http.StatusOK, []*Property{
+			{Name: "good.txt", URL: "/v1/file/testing/good.txt", Size: 4, Modified: modified},
+			{Name: "..", URL: "/v1/file/testing/..", Size: 4, Modified: modified},
+			{Name: "../escape.txt", URL: "/v1/file/escape.txt", Size: 4, Modified: modified},
+		})
+	})
+	mux.HandleFunc("/v1/file/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/file/testing/good.txt" {
+			t.Errorf("Unsafe file %s was downloaded", r.URL.Path)
+		}
+		mockRespond(w, http.StatusInternalServerError, nil)
+	})
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent, err := ioutil.TempDir("", "freeholdclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(parent)
+	dir := filepath.Join(parent, "testing")
+	err = os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "good.txt"), []byte("original"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := client.GetFile("/v1/file/testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.DownloadDir(dir, nil)
+	errs, ok := err.(PathErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("Expected three PathErrors, got %v", err)
+	}
+
+	// a failed download leaves the existing local file as it was
+	data, err := ioutil.ReadFile(filepath.Join(dir, "good.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "original" {
+		t.Errorf("Existing local file was changed by a failed download. Got %s", data)
+	}
+	files, err := ioutil.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || len(local) != 1 {
+		t.Errorf("Expected only the existing local file to remain")
+	}
+}
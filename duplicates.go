@@ -0,0 +1,356 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultPartialHashSize is the number of bytes read from the start and the end of each
+// file when comparing partial hashes, if no size is specified
+const DefaultPartialHashSize = 64 << 10
+
+// DuplicateOptions are the options used by FindDuplicates
+type DuplicateOptions struct {
+	// MinSize is the smallest file compared, defaults to 1 so empty files are ignored
+	MinSize int64
+	// PartialHashSize is the number of bytes read from the start and end of files of the
+	// same size, to rule out most differing files before their full contents are hashed.
+	// Defaults to DefaultPartialHashSize
+	PartialHashSize int64
+	// Concurrency is the number of files hashed in parallel
+	Concurrency int
+}
+
+// DuplicateGroup is a set of files with identical contents
+type DuplicateGroup struct {
+	Size  int64   `json:"size"`
+	Hash  string  `json:"hash"`
+	Files []*File `json:"files"`
+}
+
+// Reclaimable is the space freed by keeping only one of the files
+func (g *DuplicateGroup) Reclaimable() int64 {
+	return g.Size * int64(len(g.Files)-1)
+}
+
+// KeepPolicy decides which file in a group of duplicates is kept
+type KeepPolicy int
+
+// Keep policies
+const (
+	KeepOldest   KeepPolicy = iota // keep the file with the oldest modified time
+	KeepNewest                     // keep the file with the newest modified time
+	KeepFirst                      // keep the file in the earliest root searched, then by path
+	KeepShortest                   // keep the file with the shortest path
+)
+
+// Keep returns the file which is kept under the policy
+func (g *DuplicateGroup) Keep(policy KeepPolicy) *File {
+	keep := g.Files[0]
+	for _, f := range g.Files[1:] {
+		switch policy {
+		case KeepOldest:
+			if f.ModifiedTime().Before(keep.ModifiedTime()) {
+				keep = f
+			}
+		case KeepNewest:
+			if f.ModifiedTime().After(keep.ModifiedTime()) {
+				keep = f
+			}
+		case KeepShortest:
+			if len(f.URL) < len(keep.URL) {
+				keep = f
+			}
+		}
+	}
+	return keep
+}
+
+// DuplicateReport is the duplicate files found by FindDuplicates
+type DuplicateReport struct {
+	// Groups are the sets of duplicate files, with the most reclaimable space first
+	Groups []*DuplicateGroup `json:"groups"`
+	// Reclaimable is the total space freed by keeping only one file from each group
+	Reclaimable int64 `json:"reclaimable"`
+}
+
+// Remove deletes all but one file from each group of duplicates, keeping the file chosen by
+// the policy.  Before each file is deleted, it and the kept file are checked again, and the
+// file is skipped if either has changed size or modified time since the report was made, or
+// the kept file no longer exists.  The paths of the deleted files, or the files which would
+// be deleted if DryRun is set, are returned, along with any failures as PathErrors
+func (r *DuplicateReport) Remove(policy KeepPolicy, opts *TreeOptions) (TreeResults, error) {
+	if opts == nil {
+		opts = &TreeOptions{}
+	}
+
+	var results TreeResults
+	keeps := make(map[*TreeResult]*File)
+	for _, g := range r.Groups {
+		keep := g.Keep(policy)
+		for _, f := range g.Files {
+			if f != keep {
+				res := &TreeResult{Path: f.URL, prop: &f.Property}
+				results = append(results, res)
+				keeps[res] = keep
+			}
+		}
+	}
+	if opts.DryRun {
+		return results, nil
+	}
+
+	results.run(opts.Concurrency, func(res *TreeResult) error {
+		keep := keeps[res]
+		err := unchanged(&keep.Property)
+		if IsNotFound(err) {
+			return fmt.Errorf("The kept copy %s no longer exists", keep.URL)
+		}
+		if err != nil {
+			return fmt.Errorf("Couldn't check the kept copy %s: %s", keep.URL, err)
+		}
+
+		err = unchanged(res.prop)
+		if err != nil {
+			return err
+		}
+		return res.prop.Delete()
+	})
+	return results, results.Err()
+}
+
+// unchanged returns an error if the file's size or modified time on the freehold instance no
+// longer match the property
+func unchanged(p *Property) error {
+	current, err := p.client.Stat(p.URL)
+	if err != nil {
+		return err
+	}
+	if current.Size != p.Size || current.Modified != p.Modified {
+		return fmt.Errorf("%s has changed since the duplicates were found", p.URL)
+	}
+	return nil
+}
+
+// FindDuplicates searches the folders, and everything in them, for files with identical
+// contents.  Files are grouped by size, then files of the same size are compared by hashing
+// the start and end of each file with ranged reads, and only files which still match are
+// read in full.  Files which can't be listed or read are skipped, and returned along with
+// the report as PathErrors
+func (c *Client) FindDuplicates(roots []string, opts *DuplicateOptions) (*DuplicateReport, error) {
+	if opts == nil {
+		opts = &DuplicateOptions{}
+	}
+	minSize := opts.MinSize
+	if minSize <= 0 {
+		minSize = 1
+	}
+	partialSize := opts.PartialHashSize
+	if partialSize <= 0 {
+		partialSize = DefaultPartialHashSize
+	}
+
+	d := &deduper{concurrency: opts.Concurrency}
+
+	// the index of the root each file was first found in, for KeepFirst
+	rootIndex := make(map[string]int)
+	bySize := make(map[int64][]*File)
+	for i, root := range roots {
+		root = strings.TrimSuffix(root, "/")
+		err := c.Walk(root, func(p string, prop *Property, err error) error {
+			if err != nil {
+				if prop == nil {
+					return err
+				}
+				d.fail(p, err)
+				return nil
+			}
+			if prop.IsDir {
+				return nil
+			}
+			if _, ok := rootIndex[p]; ok {
+				return nil
+			}
+			size, err := prop.DataSize()
+			if err != nil {
+				d.fail(p, err)
+				return nil
+			}
+			if size < minSize {
+				return nil
+			}
+			rootIndex[p] = i
+			bySize[size] = append(bySize[size], &File{*prop})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := &DuplicateReport{}
+	for size, files := range bySize {
+		if len(files) < 2 {
+			continue
+		}
+
+		// small files are read in full by the partial hash, and transformed files can't be
+		// read from an offset without decoding everything before it, so both are hashed in full
+		full := size <= partialSize*2
+		for _, f := range files {
+			full = full || f.transformed()
+		}
+
+		if full {
+			for _, g := range d.group(files, hashRemote) {
+				report.add(size, g, rootIndex)
+			}
+			continue
+		}
+
+		partial := d.group(files, func(f *File) (string, error) {
+			return partialHash(f, size, partialSize)
+		})
+		for _, pg := range partial {
+			for _, g := range d.group(pg.files, hashRemote) {
+				report.add(size, g, rootIndex)
+			}
+		}
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.Reclaimable() != b.Reclaimable() {
+			return a.Reclaimable() > b.Reclaimable()
+		}
+		return a.Files[0].URL < b.Files[0].URL
+	})
+
+	if len(d.errs) != 0 {
+		return report, d.errs
+	}
+	return report, nil
+}
+
+// add adds the group to the report, with its files ordered by the root they were found in,
+// then by path
+func (r *DuplicateReport) add(size int64, g *hashGroup, rootIndex map[string]int) {
+	sort.Slice(g.files, func(i, j int) bool {
+		a, b := g.files[i].URL, g.files[j].URL
+		if rootIndex[a] != rootIndex[b] {
+			return rootIndex[a] < rootIndex[b]
+		}
+		return a < b
+	})
+	group := &DuplicateGroup{Size: size, Hash: g.hash, Files: g.files}
+	r.Groups = append(r.Groups, group)
+	r.Reclaimable += group.Reclaimable()
+}
+
+// hashGroup is a set of files with the same hash
+type hashGroup struct {
+	hash  string
+	files []*File
+}
+
+type deduper struct {
+	concurrency int
+
+	sync.Mutex
+	errs PathErrors
+}
+
+func (d *deduper) fail(path string, err error) {
+	d.Lock()
+	d.errs = append(d.errs, &PathError{Path: path, Err: err})
+	d.Unlock()
+}
+
+// group hashes the files in parallel, and returns the groups of more than one file with
+// the same hash.  Files which can't be hashed are recorded as failures
+func (d *deduper) group(files []*File, hash func(*File) (string, error)) []*hashGroup {
+	concurrency := d.concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	hashes := make([]string, len(files))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				h, err := hash(files[i])
+				if err != nil {
+					d.fail(files[i].URL, err)
+					continue
+				}
+				hashes[i] = h
+			}
+		}()
+	}
+	for i := range files {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	byHash := make(map[string]*hashGroup)
+	var groups []*hashGroup
+	for i, h := range hashes {
+		if h == "" {
+			continue
+		}
+		g, ok := byHash[h]
+		if !ok {
+			g = &hashGroup{hash: h}
+			byHash[h] = g
+			groups = append(groups, g)
+		}
+		g.files = append(g.files, files[i])
+	}
+
+	result := groups[:0]
+	for _, g := range groups {
+		if len(g.files) > 1 {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// partialHash hashes the first and last n bytes of the file, using a ranged read for the end
+func partialHash(f *File, size, n int64) (string, error) {
+	h := sha256.New()
+
+	head, err := f.openRange(0)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.CopyN(h, head, n)
+	head.Close()
+	if err != nil {
+		return "", err
+	}
+
+	tail, err := f.openRange(size - n)
+	if err != nil {
+		return "", err
+	}
+	defer tail.Close()
+	_, err = io.Copy(h, tail)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	old := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	photo := strings.Repeat("photo", 100)
+	// same size, start and end as photo, but a different middle
+	almost := photo[:200] + "XXXXX" + photo[205:]
+
+	m.addDir("/v1/file/photos")
+	m.addDir("/v1/file/photos/2015")
+	m.addDir("/v1/file/uploads")
+	m.addFile("/v1/file/photos/2015/beach.jpg", photo, old)
+	m.addFile("/v1/file/uploads/beach copy.jpg", photo, old.Add(time.Hour))
+	m.addFile("/v1/file/uploads/IMG_0001.jpg", photo, old.Add(2*time.Hour))
+	m.addFile("/v1/file/uploads/almost.jpg", almost, old)
+	m.addFile("/v1/file/photos/small.txt", "small", old)
+	m.addFile("/v1/file/uploads/small.txt", "small", old.Add(time.Hour))
+	m.addFile("/v1/file/uploads/other.txt", "other", old)
+	m.addFile("/v1/file/photos/empty", "", old)
+	m.addFile("/v1/file/uploads/empty", "", old)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := client.FindDuplicates([]string{"/v1/file/photos", "/v1/file/uploads/", "/v1/file/photos/2015"},
+		&DuplicateOptions{PartialHashSize: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Groups) != 2 {
+		t.Fatalf("Expected 2 duplicate groups got %d", len(report.Groups))
+	}
+	photos := report.Groups[0]
+	if len(photos.Files) != 3 || photos.Size != int64(len(photo)) {
+		t.Errorf("Photo duplicates incorrect: %d files", len(photos.Files))
+	}
+	for _, f := range photos.Files {
+		if f.Name == "almost.jpg" {
+			t.Errorf("File with a different middle was reported as a duplicate")
+		}
+	}
+	if len(report.Groups[1].Files) != 2 || report.Groups[1].Size != 5 {
+		t.Errorf("Small file duplicates incorrect")
+	}
+	if report.Reclaimable != int64(len(photo))*2+5 {
+		t.Errorf("Reclaimable space incorrect. Got %d", report.Reclaimable)
+	}
+
+	if keep := photos.Keep(KeepNewest); keep.URL != "/v1/file/uploads/IMG_0001.jpg" {
+		t.Errorf("KeepNewest kept %s", keep.URL)
+	}
+	if keep := photos.Keep(KeepFirst); keep.URL != "/v1/file/photos/2015/beach.jpg" {
+		t.Errorf("KeepFirst kept %s", keep.URL)
+	}
+	if keep := photos.Keep(KeepShortest); keep.URL != "/v1/file/uploads/IMG_0001.jpg" {
+		t.Errorf("KeepShortest kept %s", keep.URL)
+	}
+
+	results, err := report.Remove(KeepOldest, &TreeOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || m.node("/v1/file/uploads/IMG_0001.jpg") == nil {
+		t.Errorf("Dry run removed files")
+	}
+
+	// files which changed, or whose kept copy is gone, since the report are skipped
+	m.addFile("/v1/file/uploads/IMG_0001.jpg", photo, old.Add(3*time.Hour))
+	m.Lock()
+	delete(m.nodes, "/v1/file/photos/small.txt")
+	m.Unlock()
+
+	_, err = report.Remove(KeepOldest, nil)
+	errs, ok := err.(PathErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected two PathErrors got %v", err)
+	}
+	for p, exists := range map[string]bool{
+		"/v1/file/photos/2015/beach.jpg":  true,
+		"/v1/file/uploads/beach copy.jpg": false,
+		"/v1/file/uploads/IMG_0001.jpg":   true,
+		"/v1/file/uploads/almost.jpg":     true,
+		"/v1/file/uploads/small.txt":      true,
+	} {
+		if (m.node(p) != nil) != exists {
+			t.Errorf("%s exists should be %t", p, exists)
+		}
+	}
+}
@@ -0,0 +1,541 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Files are encrypted in chunks with AES-256-GCM, using a random key generated for each file.
+// The file key is wrapped with the key of each recipient, and stored in a header at the start
+// of the file:
+//
+//	magic "FHENC" | version | chunk size (uint32) | recipient count (uint8) | recipients...
+//
+// Each recipient is a type byte, followed by the passphrase salt and iteration count for
+// passphrase keys, followed by the wrapped file key (nonce + sealed key).
+// Every chunk is sealed with the header as additional data, and a nonce built from the chunk
+// number and a flag marking the final chunk, so chunks can't be reordered, truncated, or
+// moved between files.  The final chunk is always shorter than the chunk size, and may be empty.
+// Passphrase keys are derived with crypto/pbkdf2, which requires Go 1.24 or later.
+
+const (
+	encryptMagic   = "FHENC\x01"
+	keySize        = 32
+	saltSize       = 16
+	wrappedKeySize = 12 + keySize + 16
+
+	recipientKey        = 0
+	recipientPassphrase = 1
+)
+
+// DefaultChunkSize is the size of each individually encrypted chunk of a file
+const DefaultChunkSize = 64 << 10
+
+// MaxChunkSize is the largest chunk size files can be encrypted with.  Files whose header
+// claims a larger chunk size are rejected, so a tampered file can't force huge allocations
+const MaxChunkSize = 16 << 20
+
+// PassphraseIterations is the number of PBKDF2 iterations used to derive a key from a
+// passphrase when encrypting a file.  The count is stored with each file, so changing it
+// doesn't affect decrypting existing files
+var PassphraseIterations = 600000
+
+// MaxPassphraseIterations is the largest PBKDF2 iteration count files can be encrypted with.
+// Files whose header claims more iterations are rejected, so a tampered file can't tie up
+// the CPU deriving keys
+const MaxPassphraseIterations = 10000000
+
+// MaxDecryptIterations is the most PBKDF2 iterations spent looking for the key which can open
+// a file, counting every passphrase recipient in the header against every passphrase key.
+// Files which would need more are rejected before any keys are derived, so a header full of
+// high iteration recipients can't tie up the CPU either
+const MaxDecryptIterations = 2 * MaxPassphraseIterations
+
+// ErrNoMatchingKey is returned when none of the keys passed in can decrypt a file
+var ErrNoMatchingKey = errors.New("None of the keys can decrypt this file")
+
+// ErrNotEncrypted is returned when opening a file which isn't encrypted, unless the
+// Encryption allows unencrypted files
+var ErrNotEncrypted = errors.New("File is not encrypted")
+
+var errBadEncryptedFile = errors.New("Invalid or corrupt encrypted file")
+
+var errTooManyIterations = fmt.Errorf("Opening this file needs more than %d passphrase iterations",
+	MaxDecryptIterations)
+
+// Key is a key used to encrypt and decrypt files.  A key is either 32 random bytes,
+// or a passphrase from which a key is derived for each file
+type Key struct {
+	key        []byte
+	passphrase string
+}
+
+// NewKey generates a new random key
+func NewKey() (*Key, error) {
+	k := make([]byte, keySize)
+	_, err := rand.Read(k)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{key: k}, nil
+}
+
+// KeyFromBytes creates a key from 32 bytes, such as those returned from Bytes
+func KeyFromBytes(b []byte) (*Key, error) {
+	if len(b) != keySize {
+		return nil, errors.New("Key must be 32 bytes")
+	}
+	return &Key{key: append([]byte(nil), b...)}, nil
+}
+
+// PassphraseKey creates a key which is derived from the passphrase with PBKDF2 and a
+// random salt for each file
+func PassphraseKey(passphrase string) *Key {
+	return &Key{passphrase: passphrase}
+}
+
+// Bytes returns the raw bytes of the key, or nil for a passphrase key
+func (k *Key) Bytes() []byte {
+	return k.key
+}
+
+func (k *Key) derive(salt []byte, iterations int) ([]byte, error) {
+	if k.passphrase == "" {
+		return k.key, nil
+	}
+	return pbkdf2.Key(sha256.New, k.passphrase, salt, iterations, keySize)
+}
+
+// Encryption encrypts files uploaded to, and decrypts files read from a freehold instance, so
+// their contents can't be read by anyone with access to the freehold instance's storage
+type Encryption struct {
+	// ChunkSize is the size of each encrypted chunk for new files, defaults to DefaultChunkSize
+	ChunkSize int
+	// AllowUnencrypted reads files which aren't encrypted as is.  By default opening a file which
+	// isn't encrypted fails with ErrNotEncrypted, so an encrypted file replaced with other content
+	// on the freehold instance isn't silently accepted
+	AllowUnencrypted bool
+
+	client *Client
+	keys   []*Key
+}
+
+// Encryption returns an Encryption which encrypts new files so they can be decrypted by any of
+// the passed in keys, and decrypts files which any of the keys can decrypt
+func (c *Client) Encryption(keys ...*Key) *Encryption {
+	return &Encryption{
+		client:    c,
+		keys:      keys,
+		ChunkSize: DefaultChunkSize,
+	}
+}
+
+// UploadFromReader encrypts and uploads file data from the passed in reader.
+// Size is the unencrypted size, and dest must be a directory on the freehold instance
+func (e *Encryption) UploadFromReader(fileName string, r io.Reader, size int64, modTime time.Time,
+	dest *File) (*File, error) {
+	enc, err := e.encrypter(io.LimitReader(r, size))
+	if err != nil {
+		return nil, err
+	}
+
+	return e.client.UploadFromReader(fileName, enc, enc.encryptedSize(size), modTime, dest)
+}
+
+// Update encrypts and overwrites the given file with the bytes read from r.
+// Size is the unencrypted size to be read from r
+func (e *Encryption) Update(f *File, r io.Reader, size int64) error {
+	enc, err := e.encrypter(io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+
+	return f.Update(enc, enc.encryptedSize(size))
+}
+
+// GetFile retrieves and opens a file for decrypted reading
+func (e *Encryption) GetFile(filePath string) (*EncryptedFile, error) {
+	f, err := e.client.GetFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return e.Open(f)
+}
+
+// Open opens the file for decrypted reading.  Files which aren't encrypted fail with
+// ErrNotEncrypted, unless AllowUnencrypted is set, in which case they're read as is.
+// Close needs to be called when reading is completed
+func (e *Encryption) Open(f *File) (*EncryptedFile, error) {
+	ef := &EncryptedFile{
+		File: f,
+		Size: f.Size,
+	}
+
+	body, err := f.openRange(0)
+	if err != nil {
+		return nil, err
+	}
+	ef.body = body
+
+	magic := make([]byte, len(encryptMagic))
+	n, err := io.ReadFull(body, magic)
+	if err != nil || string(magic) != encryptMagic {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			err = nil
+		}
+		if err == nil && !e.AllowUnencrypted {
+			err = ErrNotEncrypted
+		}
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		ef.reader = io.MultiReader(bytes.NewReader(magic[:n]), body)
+		return ef, nil
+	}
+
+	dec, err := e.decrypter(body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	ef.Encrypted = true
+	ef.Size = plaintextSize(f.Size, int64(len(dec.header)), dec.chunkSize)
+	ef.reader = dec
+	return ef, nil
+}
+
+// EncryptedFile is a file opened for decrypted reading
+type EncryptedFile struct {
+	*File
+	// Size is the size of the decrypted file
+	Size int64
+	// Encrypted is whether or not the file on the freehold instance is encrypted
+	Encrypted bool
+
+	body   io.ReadCloser
+	reader io.Reader
+}
+
+// Read reads the decrypted file data
+func (f *EncryptedFile) Read(b []byte) (int, error) {
+	return f.reader.Read(b)
+}
+
+// Close closes the open reader
+func (f *EncryptedFile) Close() error {
+	return f.body.Close()
+}
+
+// encryptedSize is the size of the encrypted file for the given unencrypted size
+func (w *encryptReader) encryptedSize(size int64) int64 {
+	chunks := size/int64(w.chunkSize) + 1
+	return int64(len(w.header)) + size + chunks*int64(w.aead.Overhead())
+}
+
+// plaintextSize is the unencrypted size of an encrypted file
+func plaintextSize(size, headerSize int64, chunkSize int) int64 {
+	body := size - headerSize
+	sealed := int64(chunkSize + 16)
+	return body/sealed*int64(chunkSize) + body%sealed - 16
+}
+
+// chunkNonce is the nonce for the given chunk number
+func chunkNonce(chunk uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], chunk)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypter generates a new file key and header, and returns a reader which
+// encrypts the data read from r
+func (e *Encryption) encrypter(r io.Reader) (*encryptReader, error) {
+	if len(e.keys) == 0 {
+		return nil, errors.New("No encryption keys specified")
+	}
+	if len(e.keys) > 255 {
+		return nil, errors.New("Too many encryption keys specified")
+	}
+	chunkSize := e.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("Chunk size can't be larger than %d", MaxChunkSize)
+	}
+	if PassphraseIterations < 1 || PassphraseIterations > MaxPassphraseIterations {
+		return nil, fmt.Errorf("PassphraseIterations must be between 1 and %d", MaxPassphraseIterations)
+	}
+	// the same keys must always be able to open the files they encrypt
+	passphrases := e.passphrases()
+	if passphrases*passphrases*int64(PassphraseIterations) > MaxDecryptIterations {
+		return nil, errTooManyIterations
+	}
+
+	fileKey := make([]byte, keySize)
+	_, err := rand.Read(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	header.WriteString(encryptMagic)
+	binary.Write(&header, binary.BigEndian, uint32(chunkSize))
+	header.WriteByte(byte(len(e.keys)))
+
+	for _, k := range e.keys {
+		var salt []byte
+		iterations := 0
+		if k.passphrase != "" {
+			salt = make([]byte, saltSize)
+			_, err = rand.Read(salt)
+			if err != nil {
+				return nil, err
+			}
+			iterations = PassphraseIterations
+			header.WriteByte(recipientPassphrase)
+			header.Write(salt)
+			binary.Write(&header, binary.BigEndian, uint32(iterations))
+		} else {
+			header.WriteByte(recipientKey)
+		}
+
+		kek, err := k.derive(salt, iterations)
+		if err != nil {
+			return nil, err
+		}
+		wrap, err := newGCM(kek)
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, wrap.NonceSize())
+		_, err = rand.Read(nonce)
+		if err != nil {
+			return nil, err
+		}
+		header.Write(nonce)
+		header.Write(wrap.Seal(nil, nonce, fileKey, nil))
+	}
+
+	aead, err := newGCM(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptReader{
+		source:    r,
+		aead:      aead,
+		chunkSize: chunkSize,
+		header:    header.Bytes(),
+		buf:       header.Bytes(),
+	}, nil
+}
+
+// encryptReader reads from the source and returns the file header followed by
+// the encrypted chunks
+type encryptReader struct {
+	source    io.Reader
+	aead      cipher.AEAD
+	chunkSize int
+	header    []byte
+	chunk     uint64
+	buf       []byte
+	done      bool
+}
+
+func (w *encryptReader) Read(b []byte) (int, error) {
+	for len(w.buf) == 0 {
+		if w.done {
+			return 0, io.EOF
+		}
+
+		plain := make([]byte, w.chunkSize)
+		n, err := io.ReadFull(w.source, plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		last := n < w.chunkSize
+		w.buf = w.aead.Seal(nil, chunkNonce(w.chunk, last), plain[:n], w.header)
+		w.chunk++
+		w.done = last
+	}
+
+	n := copy(b, w.buf)
+	w.buf = w.buf[n:]
+	return n, nil
+}
+
+// passphrases is the number of passphrase keys the encryption uses
+func (e *Encryption) passphrases() int64 {
+	count := int64(0)
+	for _, k := range e.keys {
+		if k.passphrase != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// decrypter reads the rest of the file header, after the magic value, unwraps the file key
+// with one of the encryption's keys and returns a reader which decrypts the rest of r
+func (e *Encryption) decrypter(r io.Reader) (*decryptReader, error) {
+	header := bytes.NewBufferString(encryptMagic)
+	tr := io.TeeReader(r, header)
+
+	var chunkSize uint32
+	var count uint8
+	err := binary.Read(tr, binary.BigEndian, &chunkSize)
+	if err == nil {
+		err = binary.Read(tr, binary.BigEndian, &count)
+	}
+	if err != nil || chunkSize == 0 || chunkSize > MaxChunkSize {
+		return nil, errBadEncryptedFile
+	}
+
+	type recipient struct {
+		kind       uint8
+		salt       []byte
+		iterations uint32
+		wrapped    []byte
+	}
+	recipients := make([]recipient, count)
+	var work int64
+	for i := range recipients {
+		rcp := &recipients[i]
+		err = binary.Read(tr, binary.BigEndian, &rcp.kind)
+		if err != nil {
+			return nil, errBadEncryptedFile
+		}
+
+		if rcp.kind == recipientPassphrase {
+			rcp.salt = make([]byte, saltSize)
+			_, err = io.ReadFull(tr, rcp.salt)
+			if err == nil {
+				err = binary.Read(tr, binary.BigEndian, &rcp.iterations)
+			}
+			if err != nil || rcp.iterations < 1 || rcp.iterations > MaxPassphraseIterations {
+				return nil, errBadEncryptedFile
+			}
+			work += int64(rcp.iterations)
+		} else if rcp.kind != recipientKey {
+			return nil, errBadEncryptedFile
+		}
+
+		rcp.wrapped = make([]byte, wrappedKeySize)
+		_, err = io.ReadFull(tr, rcp.wrapped)
+		if err != nil {
+			return nil, errBadEncryptedFile
+		}
+	}
+
+	if work*e.passphrases() > MaxDecryptIterations {
+		return nil, errTooManyIterations
+	}
+
+	var fileKey []byte
+	for _, rcp := range recipients {
+		for _, k := range e.keys {
+			if (k.passphrase != "") != (rcp.kind == recipientPassphrase) {
+				continue
+			}
+			kek, err := k.derive(rcp.salt, int(rcp.iterations))
+			if err != nil {
+				return nil, err
+			}
+			wrap, err := newGCM(kek)
+			if err != nil {
+				return nil, err
+			}
+			key, err := wrap.Open(nil, rcp.wrapped[:12], rcp.wrapped[12:], nil)
+			if err == nil {
+				fileKey = key
+				break
+			}
+		}
+		if fileKey != nil {
+			break
+		}
+	}
+
+	if fileKey == nil {
+		return nil, ErrNoMatchingKey
+	}
+
+	aead, err := newGCM(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		source:    r,
+		aead:      aead,
+		chunkSize: int(chunkSize),
+		header:    header.Bytes(),
+	}, nil
+}
+
+// decryptReader decrypts and verifies each chunk read from the source
+type decryptReader struct {
+	source    io.Reader
+	aead      cipher.AEAD
+	chunkSize int
+	header    []byte
+	chunk     uint64
+	buf       []byte
+	done      bool
+}
+
+func (d *decryptReader) Read(b []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		sealed := make([]byte, d.chunkSize+d.aead.Overhead())
+		n, err := io.ReadFull(d.source, sealed)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		last := n < len(sealed)
+
+		plain, err := d.aead.Open(nil, chunkNonce(d.chunk, last), sealed[:n], d.header)
+		if err != nil {
+			return 0, errBadEncryptedFile
+		}
+		d.buf = plain
+		d.chunk++
+		d.done = last
+	}
+
+	n := copy(b, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryption(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	defer func(iterations int) { PassphraseIterations = iterations }(PassphraseIterations)
+	PassphraseIterations = 1000
+
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/plain.txt", "not encrypted", time.Now())
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphrase := PassphraseKey("correct horse battery staple")
+
+	enc := client.Encryption(key, passphrase)
+	enc.ChunkSize = 16
+
+	for _, data := range []string{"", "short", strings.Repeat("0123456789abcdef", 4), strings.Repeat("freehold", 100)} {
+		f, err := enc.UploadFromReader("secret.txt", strings.NewReader(data), int64(len(data)), time.Now(), dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stored := m.node("/v1/file/testing/secret.txt").data
+		if len(data) > 0 && bytes.Contains(stored, []byte(data)) {
+			t.Errorf("File was stored unencrypted")
+		}
+
+		// each recipient can decrypt the file on its own
+		for _, k := range []*Key{key, passphrase} {
+			ef, err := client.Encryption(k).Open(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ef.Encrypted {
+				t.Errorf("File was not opened as encrypted")
+			}
+			if ef.Size != int64(len(data)) {
+				t.Errorf("Decrypted size doesn't match. Expected %d got %d", len(data), ef.Size)
+			}
+			result, err := ioutil.ReadAll(ef)
+			ef.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result) != data {
+				t.Errorf("Decrypted data doesn't match. Expected %s got %s", data, result)
+			}
+		}
+
+		err = f.Delete()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := enc.UploadFromReader("secret.txt", strings.NewReader("secret data"), 11, time.Now(), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = enc.Update(f, strings.NewReader("updated secret data"), 19)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ef, err := enc.GetFile("/v1/file/testing/secret.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ioutil.ReadAll(ef)
+	ef.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "updated secret data" {
+		t.Errorf("Updated data doesn't match. Got %s", result)
+	}
+
+	other, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Encryption(other, PassphraseKey("wrong")).Open(f)
+	if err != ErrNoMatchingKey {
+		t.Errorf("Expected ErrNoMatchingKey got %v", err)
+	}
+
+	// tampering is detected
+	n := m.node("/v1/file/testing/secret.txt")
+	n.data[len(n.data)-1] ^= 1
+	ef, err = enc.Open(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(ef)
+	ef.Close()
+	if err == nil {
+		t.Errorf("Tampered file was decrypted without error")
+	}
+
+	// a tampered header can't force huge allocations or key derivations
+	for _, tamper := range []struct {
+		name   string
+		offset int
+	}{
+		{"chunk size", len(encryptMagic)},
+		{"iterations", len(encryptMagic) + 4 + 1 + 1 + saltSize},
+	} {
+		_, err = client.Encryption(passphrase).UploadFromReader("tampered.txt", strings.NewReader("secret"), 6,
+			time.Now(), dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := m.node("/v1/file/testing/tampered.txt")
+		copy(n.data[tamper.offset:], []byte{0xff, 0xff, 0xff, 0xff})
+		_, err = client.Encryption(passphrase).GetFile("/v1/file/testing/tampered.txt")
+		if err != errBadEncryptedFile {
+			t.Errorf("Expected a tampered %s to be rejected got %v", tamper.name, err)
+		}
+		m.Lock()
+		delete(m.nodes, "/v1/file/testing/tampered.txt")
+		m.Unlock()
+	}
+
+	// a header full of high iteration passphrase recipients is rejected before deriving any keys
+	var header bytes.Buffer
+	header.WriteString(encryptMagic)
+	binary.Write(&header, binary.BigEndian, uint32(DefaultChunkSize))
+	header.WriteByte(255)
+	for i := 0; i < 255; i++ {
+		header.WriteByte(recipientPassphrase)
+		header.Write(make([]byte, saltSize))
+		binary.Write(&header, binary.BigEndian, uint32(MaxPassphraseIterations))
+		header.Write(make([]byte, wrappedKeySize))
+	}
+	m.addFile("/v1/file/testing/expensive.txt", header.String(), time.Now())
+	start := time.Now()
+	_, err = client.Encryption(passphrase).GetFile("/v1/file/testing/expensive.txt")
+	if err != errTooManyIterations {
+		t.Errorf("Expected a header needing too many iterations to be rejected got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Rejecting a header needing too many iterations took %s", time.Since(start))
+	}
+
+	PassphraseIterations = MaxPassphraseIterations
+	_, err = client.Encryption(passphrase, PassphraseKey("other"), PassphraseKey("third")).
+		UploadFromReader("heavy.txt", strings.NewReader("secret"), 6, time.Now(), dest)
+	PassphraseIterations = 1000
+	if err != errTooManyIterations {
+		t.Errorf("Expected encrypting a file which needs too many iterations to open to fail got %v", err)
+	}
+
+	// unencrypted files are rejected unless they're allowed
+	_, err = enc.GetFile("/v1/file/testing/plain.txt")
+	if err != ErrNotEncrypted {
+		t.Errorf("Expected ErrNotEncrypted got %v", err)
+	}
+
+	enc.AllowUnencrypted = true
+	ef, err = enc.GetFile("/v1/file/testing/plain.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = ioutil.ReadAll(ef)
+	ef.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ef.Encrypted || string(result) != "not encrypted" {
+		t.Errorf("Unencrypted file was not read as is. Got %s", result)
+	}
+}
@@ -58,3 +58,64 @@ func IsNotFound(err error) bool {
 	}
 	return false
 }
+
+// ConflictError is returned when a file has been changed on the freehold instance
+// since it was last retrieved
+type ConflictError struct {
+	URL string
+	// Current is the current state of the file on the freehold instance
+	Current *Property
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s has been modified on the freehold instance.  Current modified time: %s",
+		e.URL, e.Current.Modified)
+}
+
+// IsConflict returns whether or not the error is a
+// ConflictError
+func IsConflict(err error) bool {
+	_, ok := err.(*ConflictError)
+	return ok
+}
+
+// ExistsError is returned when a file is uploaded with the same name as an existing
+// file or folder
+type ExistsError struct {
+	URL string
+	// Existing is the file or folder already on the freehold instance
+	Existing *Property
+}
+
+func (e *ExistsError) Error() string {
+	return fmt.Sprintf("%s already exists on the freehold instance", e.URL)
+}
+
+// IsExists returns whether or not the error is an
+// ExistsError
+func IsExists(err error) bool {
+	_, ok := err.(*ExistsError)
+	return ok
+}
+
+// PathError is an error that occurred while working on a single file or folder
+// as part of an operation across a tree of files
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// PathErrors is the collection of failures from an operation across a tree of files
+// which continued past individual failures
+type PathErrors []*PathError
+
+func (e PathErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d paths failed. First error: %s", len(e), e[0])
+}
@@ -6,29 +6,29 @@ package freeholdclient
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path"
 	"strings"
 	"time"
 )
 
-// multipartOverhead is how many extra bytes mime/multipart's
-// Writer adds around content
+// multipartLength is the length of a multipart body containing a single file part.  The part
+// header is measured by writing it, since mime/multipart escapes the field and file names
 // Thanks camlistore - https://github.com/camlistore/camlistore/blob/master/pkg/client/upload.go
-var multipartOverhead = func() int64 {
+func multipartLength(fieldName, fileName string, size int64) int64 {
 	var b bytes.Buffer
 	w := multipart.NewWriter(&b)
-	part, _ := w.CreateFormFile("0", "0")
-
-	dummyContents := []byte("0")
-	part.Write(dummyContents)
-
+	w.CreateFormFile(fieldName, fileName)
 	w.Close()
-	return int64(b.Len()) - 3 // remove what was added
-}()
+	return int64(b.Len()) + size
+}
 
 // File is a file stored on freehold instance
 // and the properties associated with it
@@ -38,27 +38,79 @@ type File struct {
 
 // GetFile retrieves a file for reading or writing from a freehold instance
 func (c *Client) GetFile(filePath string) (*File, error) {
-	filePath = strings.TrimSuffix(filePath, "/")
-	propPath := propertyPath(filePath)
-
-	f := &File{Property{}}
-	err := c.doRequest("GET", propPath, nil, f)
-
+	prop, err := c.Stat(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	f.client = c
-
-	return f, nil
+	return &File{*prop}, nil
 }
 
 // NewFolder creates a new folder on the freehold instance
 func (c *Client) NewFolder(folderPath string) error {
-	if !strings.HasPrefix(folderPath, "/v1/file/") {
+	fhPath, err := ParsePath(folderPath)
+	if err != nil || fhPath.Kind != KindFile || fhPath.IsRoot() {
+		return errors.New("Invalid folder path")
+	}
+	prm, err := c.newPermission(folderPath, nil)
+	if err != nil {
+		return err
+	}
+	err = c.doRequest("POST", folderPath, nil, nil)
+	if err != nil {
+		return err
+	}
+	return applyNewPermission(&Property{URL: folderPath, client: c}, prm)
+}
+
+// MkdirAll creates the folder along with any missing parent folders.  If prm isn't nil it's
+// applied to each folder created.  MkdirAll does nothing if the folder already exists
+func (c *Client) MkdirAll(folderPath string, prm *Permission) error {
+	fhPath, err := ParsePath(folderPath)
+	if err != nil || fhPath.Kind != KindFile {
 		return errors.New("Invalid folder path")
 	}
-	return c.doRequest("POST", folderPath, nil, nil)
+	if prm != nil {
+		err = prm.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// find the deepest folder which already exists
+	var missing []Path
+	for p := fhPath; !p.IsRoot(); p = p.Parent() {
+		prop, err := c.Stat(p.String())
+		if err == nil {
+			if !prop.IsDir {
+				return fmt.Errorf("%s is not a directory.", p)
+			}
+			break
+		}
+		if !IsNotFound(err) {
+			return err
+		}
+		missing = append(missing, p)
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		p := missing[i].String()
+		err = c.NewFolder(p)
+		if err != nil {
+			// the folder may have been created by someone else in the meantime
+			if prop, sErr := c.Stat(p); sErr == nil && prop.IsDir {
+				continue
+			}
+			return err
+		}
+		if prm != nil {
+			err = (&Property{URL: p, client: c}).SetPermission(prm)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // UploadFile uploads a local file to the freehold instance
@@ -76,21 +128,94 @@ func (c *Client) UploadFile(file *os.File, dest *File) (*File, error) {
 
 }
 
+// ConflictStrategy is what happens when an uploaded file has the same name
+// as an existing file
+type ConflictStrategy int
+
+// Conflict strategies
+const (
+	// ConflictFail fails the upload with an *ExistsError
+	ConflictFail ConflictStrategy = iota
+	// ConflictOverwrite overwrites the existing file
+	ConflictOverwrite
+	// ConflictRename uploads the file under the next available name, such as "file (1).txt"
+	ConflictRename
+	// ConflictSkip skips the upload if the existing file has the same size and modified time,
+	// otherwise the upload fails with an *ExistsError
+	ConflictSkip
+)
+
 // UploadFromReader uploads file data from the passed in reader
 // size is required and dest must be a directory on the freehold instance
+// If a file with the same name already exists, the client's OnConflict strategy
+// is applied
 func (c *Client) UploadFromReader(fileName string, r io.Reader, size int64, modTime time.Time, dest *File) (*File, error) {
 	if !dest.IsDir {
 		return nil, errors.New("Destination is not a directory.")
 	}
+
+	filePath := path.Join(dest.URL, fileName)
+
+	if c.OnConflict != ConflictFail {
+		existing, err := c.GetFile(filePath)
+		if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+		if existing != nil {
+			switch {
+			case existing.IsDir:
+				return nil, &ExistsError{URL: filePath, Existing: &existing.Property}
+			case c.OnConflict == ConflictOverwrite:
+				err = existing.upload("PUT", r, size, modTime)
+				if err != nil {
+					return nil, err
+				}
+				return c.GetFile(filePath)
+			case c.OnConflict == ConflictSkip:
+				existingSize, err := existing.DataSize()
+				if err != nil {
+					return nil, err
+				}
+				if existingSize == size &&
+					existing.ModifiedTime().Truncate(time.Second).Equal(modTime.Truncate(time.Second)) {
+					return existing, nil
+				}
+				return nil, &ExistsError{URL: filePath, Existing: &existing.Property}
+			case c.OnConflict == ConflictRename:
+				fileName, err = availableName(dest, fileName)
+				if err != nil {
+					return nil, err
+				}
+				filePath = path.Join(dest.URL, fileName)
+			}
+		}
+	}
+
 	f := &File{
 		Property: Property{
 			Name:   fileName,
-			URL:    path.Join(dest.URL, fileName),
+			URL:    filePath,
 			client: c,
 		},
 	}
 
-	err := f.upload("POST", r, size, modTime)
+	prm, err := c.newPermission(filePath, &dest.Property)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.upload("POST", r, size, modTime)
+	if err != nil {
+		// the file was created by someone else after it was checked for
+		if e, ok := err.(*FHError); ok && e.statusCode == http.StatusConflict {
+			if existing, gErr := c.GetFile(filePath); gErr == nil {
+				return nil, &ExistsError{URL: filePath, Existing: &existing.Property}
+			}
+		}
+		return nil, err
+	}
+
+	err = applyNewPermission(&f.Property, prm)
 	if err != nil {
 		return nil, err
 	}
@@ -98,6 +223,28 @@ func (c *Client) UploadFromReader(fileName string, r io.Reader, size int64, modT
 	return c.GetFile(f.URL)
 }
 
+// availableName returns the first name, of the form "name (n).ext", not used
+// in the destination folder
+func availableName(dest *File, fileName string) (string, error) {
+	children, err := dest.Children()
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(children))
+	for _, child := range children {
+		used[child.Name] = true
+	}
+
+	ext := path.Ext(fileName)
+	base := strings.TrimSuffix(fileName, ext)
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !used[name] {
+			return name, nil
+		}
+	}
+}
+
 // Update overwrites the given file with the bytes read from r
 // Size is the total size to be read from r, and a limitReader is used to
 // enforce this
@@ -105,9 +252,148 @@ func (f *File) Update(r io.Reader, size int64) error {
 	return f.upload("PUT", r, size, time.Time{})
 }
 
+// UpdateIfUnmodified overwrites the given file with the bytes read from r, only if the
+// file hasn't been modified on the freehold instance since expectedModified, which is usually
+// the ModifiedTime of the file when it was last read.  If the file has been changed, a
+// *ConflictError is returned containing the current properties of the file.
+// On success the file's properties are refreshed, so the new ModifiedTime can be used
+// for the next update
+func (f *File) UpdateIfUnmodified(r io.Reader, size int64, expectedModified time.Time) error {
+	expectedModified = expectedModified.Truncate(time.Second)
+
+	current, err := f.client.GetFile(f.URL)
+	if err != nil {
+		return err
+	}
+	if !current.ModifiedTime().Truncate(time.Second).Equal(expectedModified) {
+		return &ConflictError{URL: f.URL, Current: &current.Property}
+	}
+
+	header := http.Header{}
+	header.Set("If-Unmodified-Since", expectedModified.UTC().Format(http.TimeFormat))
+
+	err = f.uploadWithHeader("PUT", r, size, time.Time{}, header)
+	if e, ok := err.(*FHError); ok && e.statusCode == http.StatusPreconditionFailed {
+		current, cErr := f.client.GetFile(f.URL)
+		if cErr != nil {
+			return err
+		}
+		return &ConflictError{URL: f.URL, Current: &current.Property}
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err = f.client.GetFile(f.URL)
+	if err != nil {
+		return err
+	}
+	f.Size = current.Size
+	f.Modified = current.Modified
+	f.modTime = time.Time{}
+	return nil
+}
+
+// staleTempAge is how old a temp file left behind by ReplaceAtomic must be before it's
+// removed, so replacements still being uploaded by other writers aren't removed
+const staleTempAge = time.Hour
+
+// ReplaceAtomic overwrites the given file with the bytes read from r, without readers ever
+// seeing a partially written file.  The data is uploaded to a hidden temp file in the same folder,
+// with the client's OnConflict strategy applying to the temp file's randomly generated name, then
+// given the file's permissions, and once the upload is verified, the file is moved to a hidden
+// backup name, the temp file is moved into place, and the backup is deleted permanently, even if
+// the client's UseTrash is set.  Freehold has no way to move over an existing file, so between
+// the two moves there is a short window where readers will find no file at all.  If the temp
+// file can't be moved into place, the backup is moved back.
+// Temp files more than an hour old left behind by a previous failed replace of this file are
+// removed first.  Backups are never removed automatically, as they may be the only copy left
+// of a file whose replace failed
+func (f *File) ReplaceAtomic(r io.Reader, size int64) error {
+	filePath := strings.TrimSuffix(f.URL, "/")
+	parent, err := f.client.GetFile(path.Dir(filePath))
+	if err != nil {
+		return err
+	}
+
+	prefix := "." + f.Name + ".fhtmp-"
+	children, err := parent.Children()
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if strings.HasPrefix(child.Name, prefix) && time.Since(child.ModifiedTime()) > staleTempAge {
+			child.remove()
+		}
+	}
+
+	suffix := make([]byte, 8)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := f.client.UploadFromReader(prefix+hex.EncodeToString(suffix), r, size, time.Now(), parent)
+	if err != nil {
+		return err
+	}
+	uploaded, err := tmp.DataSize()
+	if err != nil {
+		tmp.remove()
+		return err
+	}
+	if uploaded != size {
+		tmp.remove()
+		return fmt.Errorf("Uploaded file size of %d doesn't match the expected size of %d", uploaded, size)
+	}
+
+	if f.Permissions != nil {
+		err = tmp.SetPermission(f.Permissions.withoutOwner())
+		if err != nil {
+			tmp.remove()
+			return err
+		}
+	}
+
+	backup := &File{Property{
+		URL:    path.Join(path.Dir(filePath), "."+f.Name+".fhbak-"+hex.EncodeToString(suffix)),
+		client: f.client,
+	}}
+	err = f.Move(backup.URL)
+	if err != nil {
+		tmp.remove()
+		return err
+	}
+
+	err = tmp.Move(filePath)
+	if err != nil {
+		rbErr := backup.Move(filePath)
+		if rbErr != nil {
+			return fmt.Errorf("Error moving %s into place, the previous version remains at %s and "+
+				"the new data at %s: %s", filePath, backup.URL, tmp.URL, err)
+		}
+		tmp.remove()
+		return err
+	}
+
+	current, err := f.client.GetFile(filePath)
+	if err != nil {
+		return err
+	}
+	f.Property = current.Property
+
+	err = backup.remove()
+	if err != nil {
+		return fmt.Errorf("%s was replaced, but the previous version at %s couldn't be removed: %s",
+			filePath, backup.URL, err)
+	}
+	return nil
+}
+
 // Move moves a file to a new location
 func (f *File) Move(to string) error {
-	if !strings.HasPrefix(to, "/v1/file/") {
+	fhPath, err := ParsePath(to)
+	if err != nil || fhPath.Kind != KindFile || fhPath.IsRoot() {
 		return errors.New("Invalid file path")
 	}
 	return f.client.doRequest("PUT", f.URL, map[string]string{"move": to}, nil)
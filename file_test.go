@@ -5,10 +5,13 @@
 package freeholdclient
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -86,3 +89,409 @@ func TestFile(t *testing.T) {
 	}
 
 }
+
+func TestUpdateIfUnmodified(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/test.txt", "test file", modified)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := client.GetFile("/v1/file/testing/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := client.GetFile("/v1/file/testing/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.UpdateIfUnmodified(strings.NewReader("first edit"), 10, f.ModifiedTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m.node("/v1/file/testing/test.txt").data) != "first edit" {
+		t.Errorf("File was not updated")
+	}
+	if f.ModifiedTime().Equal(modified) {
+		t.Errorf("File properties were not refreshed after update")
+	}
+
+	err = other.UpdateIfUnmodified(strings.NewReader("second edit"), 11, other.ModifiedTime())
+	if !IsConflict(err) {
+		t.Fatalf("Expected a conflict error got %v", err)
+	}
+	if err.(*ConflictError).Current.Size != 10 {
+		t.Errorf("Conflict error doesn't contain the current file properties")
+	}
+	if string(m.node("/v1/file/testing/test.txt").data) != "first edit" {
+		t.Errorf("Conflicting update overwrote the file")
+	}
+}
+
+func TestReplaceAtomic(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/test.txt", "test file", modified)
+	m.addFile("/v1/file/testing/.test.txt.fhtmp-0123456789abcdef", "left over", modified)
+	m.addFile("/v1/file/testing/.test.txt.fhtmp-fedcba9876543210", "in progress", time.Now())
+	m.addFile("/v1/file/testing/.other.txt.fhtmp-0123456789abcdef", "not ours", modified)
+	m.Lock()
+	m.nodes["/v1/file/testing/test.txt"].perm = &Permission{Owner: username, Public: "r", Private: "rw"}
+	m.Unlock()
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.UseTrash = true
+
+	f, err := client.GetFile("/v1/file/testing/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.ReplaceAtomic(strings.NewReader("replaced data"), 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(m.node("/v1/file/testing/test.txt").data) != "replaced data" {
+		t.Errorf("File was not replaced")
+	}
+	if f.Size != 13 {
+		t.Errorf("File properties were not refreshed. Expected size 13 got %d", f.Size)
+	}
+	if prm := m.node("/v1/file/testing/test.txt").perm; prm == nil || prm.Public != "r" {
+		t.Errorf("File permissions were not kept. Got %+v", prm)
+	}
+
+	m.Lock()
+	files := m.children("/v1/file/testing")
+	_, trashed := m.nodes["/v1/file/.trash-"+username]
+	m.Unlock()
+	if len(files) != 3 {
+		t.Errorf("Expected the replaced file, the in progress temp file and the other temp file "+
+			"to remain got %v", files)
+	}
+	if trashed {
+		t.Errorf("Previous version of the file was moved to the trash")
+	}
+
+	// the previous version is moved back when the new data can't be moved into place
+	m.Lock()
+	m.failMove = func(from, to string) bool {
+		return strings.Contains(from, ".fhtmp-") && to == "/v1/file/testing/test.txt"
+	}
+	m.Unlock()
+
+	err = f.ReplaceAtomic(strings.NewReader("failed data"), 11)
+	if err == nil {
+		t.Fatalf("Replacing with a failed move didn't return an error")
+	}
+	if string(m.node("/v1/file/testing/test.txt").data) != "replaced data" {
+		t.Errorf("Previous version of the file was not moved back")
+	}
+	m.Lock()
+	files = m.children("/v1/file/testing")
+	m.Unlock()
+	if len(files) != 3 {
+		t.Errorf("Expected the temp and backup files to be removed got %v", files)
+	}
+}
+
+func TestUploadConflict(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/test.txt", "test file", modified)
+	m.addFile("/v1/file/testing/test (1).txt", "test file", modified)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(data string, modTime time.Time) (*File, error) {
+		return client.UploadFromReader("test.txt", strings.NewReader(data), int64(len(data)), modTime, dest)
+	}
+
+	_, err = upload("new data", modified)
+	if !IsExists(err) {
+		t.Errorf("Expected ExistsError got %v", err)
+	}
+
+	client.OnConflict = ConflictSkip
+	f, err := upload("test file", modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL != "/v1/file/testing/test.txt" {
+		t.Errorf("Expected existing file to be returned got %s", f.URL)
+	}
+	_, err = upload("different data", modified)
+	if !IsExists(err) {
+		t.Errorf("Expected ExistsError for a different file got %v", err)
+	}
+
+	client.OnConflict = ConflictRename
+	f, err = upload("renamed", modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "test (2).txt" || string(m.node("/v1/file/testing/test (2).txt").data) != "renamed" {
+		t.Errorf("Expected upload to test (2).txt got %s", f.Name)
+	}
+
+	client.OnConflict = ConflictOverwrite
+	later := modified.Add(time.Hour)
+	_, err = upload("overwritten", later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := m.node("/v1/file/testing/test.txt")
+	if string(n.data) != "overwritten" || !n.modified.Equal(later) {
+		t.Errorf("File was not overwritten. Got %s modified %v", n.data, n.modified)
+	}
+}
+
+func TestUploadFailureNotExists(t *testing.T) {
+	startMockServer()
+	defer stopMockServer()
+
+	mux.HandleFunc("/v1/properties/file/testing", func(w http.ResponseWriter, r *http.Request) {
+		mockRespond(w, http.StatusOK, &Property{Name: "testing", URL: "/v1/file/testing/", IsDir: true})
+	})
+	mux.HandleFunc("/v1/properties/file/testing/test.txt", func(w http.ResponseWriter, r *http.Request) {
+		mockRespond(w, http.StatusOK, &Property{Name: "test.txt", URL: "/v1/file/testing/test.txt", Size: 9})
+	})
+	mux.HandleFunc("/v1/file/testing", func(w http.ResponseWriter, r *http.Request) {
+		mockRespond(w, http.StatusInternalServerError, "disk full")
+	})
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := client.GetFile("/v1/file/testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the server reporting a conflict is an ExistsError
+	_, err = client.UploadFromReader("test.txt", strings.NewReader("new data"), 8, time.Now(), dest)
+	if err == nil || IsExists(err) {
+		t.Errorf("Expected the server error got %v", err)
+	}
+}
+
+func TestSpecialCharacterNames(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addDir("/v1/file/testing")
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{
+		"with space.txt",
+		"hash#tag.txt",
+		"question?.txt",
+		"percent%20.txt",
+		"bad%zzescape.txt",
+		"plus+semi;amp&.txt",
+		`quote".txt`,
+		`back\slash.txt`,
+		"ünïcødé 日本語.txt",
+	}
+
+	for _, name := range names {
+		data := "data for " + name
+		f, err := client.UploadFromReader(name, strings.NewReader(data), int64(len(data)), time.Now(), dest)
+		if err != nil {
+			t.Errorf("Error uploading %q: %s", name, err)
+			continue
+		}
+		if m.node("/v1/file/testing/"+name) == nil {
+			t.Errorf("%q was not stored under its name", name)
+		}
+		if f.Name != name || f.URL != "/v1/file/testing/"+name {
+			t.Errorf("Uploaded file name or url is incorrect. Got %q, %q", f.Name, f.URL)
+		}
+
+		result, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("Error reading %q: %s", name, err)
+			continue
+		}
+		if string(result) != data {
+			t.Errorf("Data for %q doesn't match. Got %q", name, result)
+		}
+
+		moved := "moved " + name
+		err = f.Move("/v1/file/testing/" + moved)
+		if err != nil {
+			t.Errorf("Error moving %q: %s", name, err)
+			continue
+		}
+		f, err = client.GetFile("/v1/file/testing/" + moved)
+		if err != nil {
+			t.Errorf("Error getting %q: %s", moved, err)
+			continue
+		}
+		if f.URL != "/v1/file/testing/"+moved {
+			t.Errorf("Moved file url is incorrect. Got %q", f.URL)
+		}
+	}
+
+	folder := "/v1/file/testing/folder #1 100%"
+	err = client.NewFolder(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	children, err := dest.Children()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != len(names)+1 {
+		t.Fatalf("Expected %d children got %d", len(names)+1, len(children))
+	}
+	for _, child := range children {
+		if _, err := client.GetFile(child.URL); err != nil {
+			t.Errorf("Error getting child %q: %s", child.URL, err)
+		}
+	}
+}
+
+func TestMultipartLength(t *testing.T) {
+	names := []string{
+		"plain.txt",
+		`quote".txt`,
+		`back\slash.txt`,
+		"new\nline.txt",
+		"cr\rx.txt",
+		"ünïcødé 日本語.txt",
+	}
+
+	for _, name := range names {
+		data := []byte("data for " + name)
+
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		part, err := w.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+		w.Close()
+
+		length := multipartLength("file", name, int64(len(data)))
+		if length != int64(b.Len()) {
+			t.Errorf("Multipart length for %q is incorrect. Expected %d got %d", name, b.Len(), length)
+		}
+	}
+}
+
+func TestMkdirAllAndExists(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/file.txt", "data", time.Now())
+	m.addDir("/v1/datastore")
+	m.addFile("/v1/datastore/test.ds", "", time.Now())
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.MkdirAll("/v1/file/testing/a/b/c/", PublicReadPermission())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/v1/file/testing/a", "/v1/file/testing/a/b", "/v1/file/testing/a/b/c"} {
+		n := m.node(p)
+		if n == nil || !n.isDir {
+			t.Fatalf("%s was not created", p)
+		}
+		if n.perm == nil || n.perm.Public != "r" {
+			t.Errorf("Permissions not applied to %s. Got %+v", p, n.perm)
+		}
+	}
+	if m.node("/v1/file/testing").perm != nil {
+		t.Errorf("Permissions applied to existing folder")
+	}
+
+	err = client.MkdirAll("/v1/file/testing/a/b/c", nil)
+	if err != nil {
+		t.Errorf("MkdirAll on existing folder failed: %s", err)
+	}
+
+	err = client.MkdirAll("/v1/file/testing/file.txt/sub", nil)
+	if err == nil {
+		t.Errorf("MkdirAll under a file did not fail")
+	}
+
+	err = client.MkdirAll("/v1/datastore/folder", nil)
+	if err == nil {
+		t.Errorf("MkdirAll of a datastore path did not fail")
+	}
+
+	for p, expected := range map[string]bool{
+		"/v1/file/testing/a/b":        true,
+		"/v1/file/testing/file.txt":   true,
+		"/v1/datastore/test.ds":       true,
+		"/v1/file/testing/missing":    false,
+		"/v1/datastore/missing.ds":    false,
+		"/v1/file/testing/a/b/c/d/e/": false,
+	} {
+		exists, err := client.Exists(p)
+		if err != nil {
+			t.Errorf("Error checking %s: %s", p, err)
+		}
+		if exists != expected {
+			t.Errorf("Exists(%s) expected %t got %t", p, expected, exists)
+		}
+	}
+
+	prop, err := client.Stat("/v1/file/testing/a/b/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prop.IsDir || prop.Name != "b" {
+		t.Errorf("Stat returned incorrect properties: %+v", prop)
+	}
+
+	_, err = client.Exists("/v1/properties/file/testing")
+	if err == nil {
+		t.Errorf("Exists with an invalid path did not fail")
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS returns a read only file system rooted at the passed in freehold folder, which
+// can be used anywhere an io/fs file system is accepted, such as http.FS, template.ParseFS,
+// or fs.WalkDir.  The returned file system also implements fs.ReadDirFS, fs.StatFS,
+// and fs.ReadFileFS.
+func (c *Client) FS(root string) fs.FS {
+	return &fileSystem{
+		client: c,
+		root:   strings.TrimSuffix(root, "/"),
+	}
+}
+
+type fileSystem struct {
+	client *Client
+	root   string
+}
+
+func (fsys *fileSystem) get(op, name string) (*File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	p := fsys.root
+	if name != "." {
+		p += "/" + name
+	}
+
+	f, err := fsys.client.GetFile(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fsError(err)}
+	}
+	return f, nil
+}
+
+// Open opens the named file or folder for reading
+func (fsys *fileSystem) Open(name string) (fs.File, error) {
+	f, err := fsys.get("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := newFileInfo(&f.Property)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fsError(err)}
+	}
+	if f.IsDir {
+		return &fsDir{file: f, info: info, name: name}, nil
+	}
+	return &fsFile{file: f, info: info, name: name}, nil
+}
+
+// Stat returns the FileInfo for the named file or folder
+func (fsys *fileSystem) Stat(name string) (fs.FileInfo, error) {
+	f, err := fsys.get("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := newFileInfo(&f.Property)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fsError(err)}
+	}
+	return info, nil
+}
+
+// ReadDir reads the named folder and returns its entries sorted by name
+func (fsys *fileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := fsys.get("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	entries, err := dirEntries(f)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fsError(err)}
+	}
+	return entries, nil
+}
+
+// ReadFile reads the entire contents of the named file
+func (fsys *fileSystem) ReadFile(name string) ([]byte, error) {
+	f, err := fsys.get("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.IsDir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fsError(err)}
+	}
+	return data, nil
+}
+
+// fsError translates freehold errors into their io/fs equivalents
+func fsError(err error) error {
+	if e, ok := err.(*FHError); ok {
+		switch e.statusCode {
+		case http.StatusNotFound:
+			return fs.ErrNotExist
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fs.ErrPermission
+		}
+	}
+	return err
+}
+
+func dirEntries(f *File) ([]fs.DirEntry, error) {
+	children, err := f.Property.Children()
+	if err != nil {
+		return nil, err
+	}
+	children = withoutTrash(children)
+	sort.Sort(propertiesByName(children))
+
+	entries := make([]fs.DirEntry, len(children))
+	for i := range children {
+		entries[i] = &dirEntry{&children[i]}
+	}
+	return entries, nil
+}
+
+// dirEntry is a file or folder in a listing.  The size of transformed files is only
+// read when Info is called
+type dirEntry struct {
+	prop *Property
+}
+
+func (e *dirEntry) Name() string               { return e.prop.Name }
+func (e *dirEntry) IsDir() bool                { return e.prop.IsDir }
+func (e *dirEntry) Info() (fs.FileInfo, error) { return newFileInfo(e.prop) }
+
+func (e *dirEntry) Type() fs.FileMode {
+	if e.prop.IsDir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+// fileInfo describes a freehold file or folder as an fs.FileInfo.  Freehold's private,
+// friend, and public permissions are mapped to the owner, group, and other permission
+// bits respectively
+type fileInfo struct {
+	prop *Property
+	// size is the size of the file's data as it's read, see DataSize
+	size int64
+}
+
+func newFileInfo(prop *Property) (*fileInfo, error) {
+	size, err := prop.DataSize()
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{prop: prop, size: size}, nil
+}
+
+func (i *fileInfo) Name() string       { return i.prop.Name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.prop.ModifiedTime() }
+func (i *fileInfo) IsDir() bool        { return i.prop.IsDir }
+
+// Sys returns the underlying *Property
+func (i *fileInfo) Sys() interface{} { return i.prop }
+
+func (i *fileInfo) Mode() fs.FileMode {
+	return permissionMode(i.prop.Permissions, i.prop.IsDir)
+}
+
+func permissionMode(prm *Permission, isDir bool) fs.FileMode {
+	var mode fs.FileMode
+	if isDir {
+		mode |= fs.ModeDir
+	}
+	if prm == nil {
+		return mode
+	}
+
+	access := func(role Role) fs.FileMode {
+		var m fs.FileMode
+		a, _ := prm.Access(role)
+		if a&Read != 0 {
+			m |= 04
+			if isDir {
+				m |= 01
+			}
+		}
+		if a&Write != 0 {
+			m |= 02
+		}
+		return m
+	}
+
+	return mode | access(RolePrivate)<<6 | access(RoleFriend)<<3 | access(RolePublic)
+}
+
+// fsFile is an open freehold file, which can be read and seeked
+type fsFile struct {
+	file   *File
+	info   *fileInfo
+	name   string
+	offset int64
+	body   io.ReadCloser
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fsFile) Read(b []byte) (int, error) {
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		body, err := f.file.openRange(f.offset)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: fsError(err)}
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(b)
+	f.offset += int64(n)
+	return n, err
+}
+
+// Seek sets the offset for the next Read. Reading after a seek opens a new ranged
+// request against the freehold instance
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *fsFile) Close() error {
+	if f.body != nil {
+		err := f.body.Close()
+		f.body = nil
+		return err
+	}
+	return nil
+}
+
+// fsDir is an open freehold folder
+type fsDir struct {
+	file    *File
+	info    *fileInfo
+	name    string
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *fsDir) Close() error { return nil }
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := dirEntries(d.file)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fsError(err)}
+		}
+		d.entries = entries
+		d.read = true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
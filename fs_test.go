@@ -0,0 +1,68 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/test.txt", "test file", modified)
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/sub/index.html", "<html>index</html>", modified)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := client.FS(dirPath)
+
+	err = fstest.TestFS(fsys, "test.txt", "sub/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fs.Stat(fsys, "test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 9 || !info.ModTime().Equal(modified) || info.Mode() != 0600 {
+		t.Errorf("FileInfo does not match. Got size %d, modified %v, mode %v", info.Size(), info.ModTime(), info.Mode())
+	}
+
+	_, err = fs.Stat(fsys, "missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist got %v", err)
+	}
+
+	fileServer := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer fileServer.Close()
+
+	res, err := http.Get(fileServer.URL + "/sub/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "<html>index</html>" {
+		t.Errorf("File server response doesn't match. Got %s", body)
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultManifestFile is the name of the manifest file, stored in the root of the folder
+const DefaultManifestFile = ".freehold-manifest"
+
+// ManifestOptions are the options used when writing and verifying manifests
+type ManifestOptions struct {
+	// Name is the name of the manifest file in the folder, defaults to DefaultManifestFile
+	Name string
+	// Concurrency is the number of files hashed in parallel
+	Concurrency int
+}
+
+// Manifest is the SHA-256 hash of every file in a folder, keyed by the slash separated
+// path of the file relative to the folder.  It's stored in the same format as the output
+// of sha256sum, so it can also be checked with sha256sum -c against a local copy.  As with
+// sha256sum, the line of a path containing a backslash, new line, or carriage return starts
+// with a backslash, and those characters are escaped in the path
+type Manifest map[string]string
+
+// manifestEscaper escapes paths the same way sha256sum does
+var manifestEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+
+// ManifestReport is the result of verifying a folder against its manifest
+type ManifestReport struct {
+	// Verified is the number of files which match the manifest
+	Verified int `json:"verified"`
+	// Corrupted is the files whose contents no longer match the manifest
+	Corrupted []string `json:"corrupted,omitempty"`
+	// Missing is the files in the manifest which no longer exist
+	Missing []string `json:"missing,omitempty"`
+	// Unexpected is the files which aren't in the manifest
+	Unexpected []string `json:"unexpected,omitempty"`
+}
+
+// OK is whether or not every file matched the manifest
+func (r *ManifestReport) OK() bool {
+	return len(r.Corrupted) == 0 && len(r.Missing) == 0 && len(r.Unexpected) == 0
+}
+
+// WriteManifest hashes every file in the folder, streaming each file's data from the freehold
+// instance, and stores the hashes as a manifest file in the root of the folder, replacing any
+// existing manifest.  If any file can't be hashed no manifest is written, and the failures are
+// returned as PathErrors
+func (f *File) WriteManifest(opts *ManifestOptions) (Manifest, error) {
+	if !f.IsDir {
+		return nil, errors.New("File is not a directory.")
+	}
+	name := manifestName(opts)
+
+	manifest, errs, err := f.hashTree(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	w, err := f.client.Create(strings.TrimSuffix(f.URL, "/") + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(manifest.bytes())
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadManifest reads the manifest stored in the folder
+func (f *File) ReadManifest(opts *ManifestOptions) (Manifest, error) {
+	mf, err := f.client.GetFile(strings.TrimSuffix(f.URL, "/") + "/" + manifestName(opts))
+	if err != nil {
+		return nil, err
+	}
+	defer mf.Close()
+	return parseManifest(mf)
+}
+
+// VerifyManifest hashes every file in the folder again, and compares the hashes against the
+// manifest stored in the folder.  Files which can't be read are returned as PathErrors along
+// with the report for the rest of the folder
+func (f *File) VerifyManifest(opts *ManifestOptions) (*ManifestReport, error) {
+	if !f.IsDir {
+		return nil, errors.New("File is not a directory.")
+	}
+
+	expected, err := f.ReadManifest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	current, errs, err := f.hashTree(manifestName(opts), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// files which couldn't be read, or are in folders which couldn't be listed,
+	// aren't reported as missing
+	root := strings.TrimSuffix(f.URL, "/") + "/"
+	failed := func(p string) bool {
+		for _, e := range errs {
+			rel := strings.TrimPrefix(e.Path, root)
+			if p == rel || strings.HasPrefix(p, rel+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	report := &ManifestReport{}
+	for p, hash := range expected {
+		currentHash, ok := current[p]
+		switch {
+		case !ok && failed(p):
+		case !ok:
+			report.Missing = append(report.Missing, p)
+		case currentHash != hash:
+			report.Corrupted = append(report.Corrupted, p)
+		default:
+			report.Verified++
+		}
+	}
+	for p := range current {
+		if _, ok := expected[p]; !ok {
+			report.Unexpected = append(report.Unexpected, p)
+		}
+	}
+
+	sort.Strings(report.Corrupted)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Unexpected)
+
+	if len(errs) != 0 {
+		return report, errs
+	}
+	return report, nil
+}
+
+func manifestName(opts *ManifestOptions) string {
+	if opts == nil || opts.Name == "" {
+		return DefaultManifestFile
+	}
+	return opts.Name
+}
+
+// hashTree hashes every file in the folder except for the manifest.  Files which
+// can't be hashed are returned as PathErrors
+func (f *File) hashTree(manifest string, opts *ManifestOptions) (Manifest, PathErrors, error) {
+	concurrency := defaultConcurrency
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+
+	root := strings.TrimSuffix(f.URL, "/")
+	result := make(Manifest)
+	var errs PathErrors
+	var lock sync.Mutex
+
+	files := make(chan *File)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				hash, err := hashRemote(file)
+				lock.Lock()
+				if err != nil {
+					errs = append(errs, &PathError{Path: file.URL, Err: err})
+				} else {
+					result[strings.TrimPrefix(file.URL, root+"/")] = hash
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	err := f.client.Walk(root, func(p string, prop *Property, err error) error {
+		if err != nil {
+			if prop == nil {
+				return err
+			}
+			lock.Lock()
+			errs = append(errs, &PathError{Path: p, Err: err})
+			lock.Unlock()
+			return nil
+		}
+		if prop.IsDir || p == root+"/"+manifest {
+			return nil
+		}
+		files <- &File{*prop}
+		return nil
+	})
+	close(files)
+	wg.Wait()
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, errs, nil
+}
+
+// bytes returns the manifest in sha256sum format, sorted by path
+func (m Manifest) bytes() []byte {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var b bytes.Buffer
+	for _, p := range paths {
+		if strings.ContainsAny(p, "\\\n\r") {
+			fmt.Fprintf(&b, "\\%s  %s\n", m[p], manifestEscaper.Replace(p))
+			continue
+		}
+		fmt.Fprintf(&b, "%s  %s\n", m[p], p)
+	}
+	return b.Bytes()
+}
+
+// unescapeManifestPath reverses the escaping of a path on a line starting with a backslash
+func unescapeManifestPath(p string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] != '\\' {
+			b.WriteByte(p[i])
+			continue
+		}
+		i++
+		if i == len(p) {
+			return "", fmt.Errorf("Invalid escape at the end of manifest path %q", p)
+		}
+		switch p[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			return "", fmt.Errorf("Invalid escape in manifest path %q", p)
+		}
+	}
+	return b.String(), nil
+}
+
+func parseManifest(r io.Reader) (Manifest, error) {
+	m := make(Manifest)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		// sha256sum separates the hash and path with a space and either another
+		// space, or a * for binary mode
+		if len(line) < 66 || line[64] != ' ' || (line[65] != ' ' && line[65] != '*') {
+			return nil, fmt.Errorf("Invalid manifest line: %q", line)
+		}
+		p := line[66:]
+		if escaped {
+			var err error
+			p, err = unescapeManifestPath(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		m[p] = line[:64]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifest(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addDir("/v1/file/testing")
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/a.txt", "file a", time.Now())
+	m.addFile("/v1/file/testing/sub/b.txt", "file b", time.Now())
+	m.addFile("/v1/file/testing/sub/c.txt", "file c", time.Now())
+	m.addFile("/v1/file/testing/new\nline\\back.txt", "odd name", time.Now())
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	folder, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := folder.WriteManifest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 4 {
+		t.Fatalf("Expected 4 files in the manifest got %d", len(manifest))
+	}
+
+	stored := m.node("/v1/file/testing/" + DefaultManifestFile)
+	if stored == nil {
+		t.Fatal("Manifest was not stored in the folder")
+	}
+	line := fmt.Sprintf("%x  sub/b.txt\n", sha256.Sum256([]byte("file b")))
+	if !strings.Contains(string(stored.data), line) {
+		t.Errorf("Manifest is not in sha256sum format: %s", stored.data)
+	}
+	// names with new lines and backslashes are escaped the same way sha256sum does
+	line = fmt.Sprintf("\\%x  new\\nline\\\\back.txt\n", sha256.Sum256([]byte("odd name")))
+	if !strings.Contains(string(stored.data), line) {
+		t.Errorf("Manifest path was not escaped: %q", stored.data)
+	}
+	read, err := folder.ReadManifest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, manifest) {
+		t.Errorf("Manifest read back doesn't match. Got %v", read)
+	}
+
+	report, err := folder.VerifyManifest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Verified != 4 {
+		t.Errorf("Unchanged folder did not verify: %+v", report)
+	}
+
+	// rewriting the manifest doesn't include the manifest itself
+	manifest, err = folder.WriteManifest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 4 {
+		t.Errorf("Manifest included itself")
+	}
+
+	m.node("/v1/file/testing/a.txt").data[0] ^= 1
+	m.Lock()
+	delete(m.nodes, "/v1/file/testing/sub/c.txt")
+	m.Unlock()
+	m.addFile("/v1/file/testing/sub/new.txt", "new", time.Now())
+
+	report, err = folder.VerifyManifest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() || report.Verified != 2 {
+		t.Errorf("Changed folder verified: %+v", report)
+	}
+	if !reflect.DeepEqual(report.Corrupted, []string{"a.txt"}) {
+		t.Errorf("Expected a.txt to be corrupted got %v", report.Corrupted)
+	}
+	if !reflect.DeepEqual(report.Missing, []string{"sub/c.txt"}) {
+		t.Errorf("Expected sub/c.txt to be missing got %v", report.Missing)
+	}
+	if !reflect.DeepEqual(report.Unexpected, []string{"sub/new.txt"}) {
+		t.Errorf("Expected sub/new.txt to be unexpected got %v", report.Unexpected)
+	}
+
+	_, err = folder.VerifyManifest(&ManifestOptions{Name: "other-manifest"})
+	if !IsNotFound(err) {
+		t.Errorf("Expected not found verifying a missing manifest got %v", err)
+	}
+}
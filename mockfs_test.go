@@ -0,0 +1,363 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockNode is a single file or folder stored in the mock freehold instance
+type mockNode struct {
+	isDir    bool
+	data     []byte
+	modified time.Time
+	perm     *Permission
+	// kv is the key / values of a datastore created through the mock, keyed by the json
+	// encoded key
+	kv map[string]json.RawMessage
+}
+
+// mockFreehold is a minimal in memory freehold file server used for testing
+// client side operations that work across whole trees of files
+type mockFreehold struct {
+	sync.Mutex
+	nodes map[string]*mockNode
+	// failMove, if set, fails the moves it returns true for
+	failMove func(from, to string) bool
+	// failPermissions fails every request to set permissions
+	failPermissions bool
+	// reads is the Range header of each request for file data
+	reads []string
+}
+
+// startMockFreehold starts the mock server with a stateful freehold file
+// handler and an empty /v1/file/ root folder
+func startMockFreehold() *mockFreehold {
+	startMockServer()
+
+	m := &mockFreehold{
+		nodes: map[string]*mockNode{
+			"/v1/file": &mockNode{isDir: true, modified: time.Now()},
+		},
+	}
+
+	mux.HandleFunc("/v1/properties/", m.properties)
+	mux.HandleFunc("/v1/file/", m.file)
+	mux.HandleFunc("/v1/datastore/", m.datastore)
+	return m
+}
+
+func (m *mockFreehold) addDir(p string) {
+	m.Lock()
+	defer m.Unlock()
+	m.nodes[path.Clean(p)] = &mockNode{isDir: true, modified: time.Now()}
+}
+
+func (m *mockFreehold) addFile(p, data string, modified time.Time) {
+	m.Lock()
+	defer m.Unlock()
+	m.nodes[path.Clean(p)] = &mockNode{data: []byte(data), modified: modified}
+}
+
+func (m *mockFreehold) node(p string) *mockNode {
+	m.Lock()
+	defer m.Unlock()
+	return m.nodes[path.Clean(p)]
+}
+
+func (m *mockFreehold) children(dir string) []string {
+	var names []string
+	for k := range m.nodes {
+		if k != dir && path.Dir(k) == dir {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *mockFreehold) property(p string) *Property {
+	n := m.nodes[p]
+	prop := &Property{
+		Name:        path.Base(p),
+		URL:         p,
+		Permissions: n.perm,
+		Modified:    n.modified.Format(time.RFC3339),
+		IsDir:       n.isDir,
+	}
+	if n.isDir {
+		prop.URL += "/"
+	} else {
+		prop.Size = int64(len(n.data))
+	}
+	if prop.Permissions == nil {
+		prop.Permissions = &Permission{Owner: username, Private: "rw"}
+	}
+	return prop
+}
+
+func mockRespond(w http.ResponseWriter, status int, data interface{}) {
+	res := map[string]interface{}{"status": "success"}
+	if status >= 400 {
+		res["status"] = "fail"
+		res["message"] = http.StatusText(status)
+	} else if data != nil {
+		res["data"] = data
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (m *mockFreehold) properties(w http.ResponseWriter, r *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+
+	p := "/v1" + strings.TrimPrefix(r.URL.Path, "/v1/properties")
+	n, ok := m.nodes[path.Clean(p)]
+	if !ok {
+		mockRespond(w, http.StatusNotFound, nil)
+		return
+	}
+
+	if n.isDir && strings.HasSuffix(p, "/") {
+		children := []*Property{}
+		for _, c := range m.children(path.Clean(p)) {
+			children = append(children, m.property(c))
+		}
+		mockRespond(w, http.StatusOK, children)
+		return
+	}
+	mockRespond(w, http.StatusOK, m.property(path.Clean(p)))
+}
+
+func (m *mockFreehold) file(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean(r.URL.Path)
+	multipartReq := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+
+	// uploaded data is read before locking, as it may be streamed from
+	// another request to the mock, such as during a copy
+	var fileName string
+	var data []byte
+	if multipartReq {
+		part, err := r.MultipartReader()
+		if err != nil {
+			mockRespond(w, http.StatusBadRequest, nil)
+			return
+		}
+		prt, err := part.NextPart()
+		if err != nil {
+			mockRespond(w, http.StatusBadRequest, nil)
+			return
+		}
+		data, err = ioutil.ReadAll(prt)
+		if err != nil {
+			mockRespond(w, http.StatusBadRequest, nil)
+			return
+		}
+		fileName = prt.FileName()
+	}
+
+	m.Lock()
+	n, ok := m.nodes[p]
+
+	if r.Method == "GET" && !multipartReq {
+		if !ok || n.isDir {
+			m.Unlock()
+			mockRespond(w, http.StatusNotFound, nil)
+			return
+		}
+		data, modified := n.data, n.modified
+		m.reads = append(m.reads, r.Header.Get("Range"))
+		m.Unlock()
+		http.ServeContent(w, r, path.Base(p), modified, bytes.NewReader(data))
+		return
+	}
+	defer m.Unlock()
+
+	switch {
+	case multipartReq:
+		if !ok || !n.isDir {
+			mockRespond(w, http.StatusNotFound, nil)
+			return
+		}
+
+		fp := path.Join(p, fileName)
+		existing, exists := m.nodes[fp]
+		if r.Method == "POST" && exists {
+			mockRespond(w, http.StatusConflict, nil)
+			return
+		}
+		if r.Method == "PUT" {
+			if !exists {
+				mockRespond(w, http.StatusNotFound, nil)
+				return
+			}
+			if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil &&
+				existing.modified.Truncate(time.Second).After(since) {
+				mockRespond(w, http.StatusPreconditionFailed, nil)
+				return
+			}
+		}
+
+		modified := time.Now()
+		if fhMod, err := time.Parse(time.RFC3339, r.Header.Get("Fh-Modified")); err == nil {
+			modified = fhMod
+		}
+		node := &mockNode{data: data, modified: modified}
+		if exists {
+			node.perm = existing.perm
+		}
+		m.nodes[fp] = node
+		mockRespond(w, http.StatusCreated, fp)
+	case r.Method == "POST":
+		parent, pok := m.nodes[path.Dir(p)]
+		if ok || !pok || !parent.isDir {
+			mockRespond(w, http.StatusConflict, nil)
+			return
+		}
+		m.nodes[p] = &mockNode{isDir: true, modified: time.Now()}
+		mockRespond(w, http.StatusCreated, nil)
+	case r.Method == "PUT":
+		if !ok {
+			mockRespond(w, http.StatusNotFound, nil)
+			return
+		}
+		input := struct {
+			Move        string      `json:"move"`
+			Permissions *Permission `json:"permissions"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			mockRespond(w, http.StatusBadRequest, nil)
+			return
+		}
+		if input.Permissions != nil {
+			if m.failPermissions {
+				mockRespond(w, http.StatusInternalServerError, nil)
+				return
+			}
+			n.perm = input.Permissions
+		}
+		if input.Move != "" {
+			to := path.Clean(input.Move)
+			if m.failMove != nil && m.failMove(p, to) {
+				mockRespond(w, http.StatusInternalServerError, nil)
+				return
+			}
+			if _, exists := m.nodes[to]; exists {
+				mockRespond(w, http.StatusConflict, nil)
+				return
+			}
+			if parent, pok := m.nodes[path.Dir(to)]; !pok || !parent.isDir {
+				mockRespond(w, http.StatusNotFound, nil)
+				return
+			}
+			for k, v := range m.nodes {
+				if k == p || strings.HasPrefix(k, p+"/") {
+					delete(m.nodes, k)
+					m.nodes[to+strings.TrimPrefix(k, p)] = v
+				}
+			}
+		}
+		mockRespond(w, http.StatusOK, nil)
+	case r.Method == "DELETE":
+		if !ok {
+			mockRespond(w, http.StatusNotFound, nil)
+			return
+		}
+		if len(m.children(p)) != 0 {
+			mockRespond(w, http.StatusBadRequest, nil)
+			return
+		}
+		delete(m.nodes, p)
+		mockRespond(w, http.StatusOK, nil)
+	default:
+		mockRespond(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s not allowed", r.Method))
+	}
+}
+
+// datastore handles creating and dropping datastores, and the key / value requests used
+// by the client.  Iterators ignore everything but the key order
+func (m *mockFreehold) datastore(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean(r.URL.Path)
+	input := struct {
+		Key   *json.RawMessage `json:"key"`
+		Value *json.RawMessage `json:"value"`
+		Iter  *Iter            `json:"iter"`
+	}{}
+	if r.ContentLength > 0 {
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			mockRespond(w, http.StatusBadRequest, nil)
+			return
+		}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	n, ok := m.nodes[p]
+
+	if r.Method == "POST" {
+		parent, pok := m.nodes[path.Dir(p)]
+		if ok || !pok || !parent.isDir {
+			mockRespond(w, http.StatusConflict, nil)
+			return
+		}
+		m.nodes[p] = &mockNode{modified: time.Now(), kv: make(map[string]json.RawMessage)}
+		mockRespond(w, http.StatusCreated, nil)
+		return
+	}
+	if !ok || n.isDir {
+		mockRespond(w, http.StatusNotFound, nil)
+		return
+	}
+
+	switch {
+	case r.Method == "DELETE" && input.Key == nil:
+		delete(m.nodes, p)
+		mockRespond(w, http.StatusOK, nil)
+	case n.kv == nil:
+		mockRespond(w, http.StatusBadRequest, nil)
+	case r.Method == "GET" && input.Iter != nil:
+		keys := make([]string, 0, len(n.kv))
+		for k := range n.kv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		result := []*KeyValue{}
+		for _, k := range keys {
+			key, value := json.RawMessage(k), n.kv[k]
+			result = append(result, &KeyValue{K: &key, V: &value})
+		}
+		mockRespond(w, http.StatusOK, result)
+	case input.Key == nil:
+		mockRespond(w, http.StatusBadRequest, nil)
+	case r.Method == "GET":
+		value, ok := n.kv[string(*input.Key)]
+		if !ok {
+			mockRespond(w, http.StatusNotFound, nil)
+			return
+		}
+		mockRespond(w, http.StatusOK, value)
+	case r.Method == "PUT" && input.Value != nil:
+		n.kv[string(*input.Key)] = *input.Value
+		n.modified = time.Now()
+		mockRespond(w, http.StatusOK, nil)
+	case r.Method == "DELETE":
+		delete(n.kv, string(*input.Key))
+		mockRespond(w, http.StatusOK, nil)
+	default:
+		mockRespond(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s not allowed", r.Method))
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ResourceKind is the type of resource a freehold path points to
+type ResourceKind string
+
+// Resource kinds
+const (
+	KindFile      ResourceKind = "file"
+	KindDatastore ResourceKind = "datastore"
+)
+
+// Path is a parsed freehold file or datastore path, such as /v1/file/folder/test.txt, or
+// /appid/v1/datastore/test.ds for a path belonging to an application
+type Path struct {
+	// App is the application ID, or empty for paths outside of an application
+	App     string
+	Version string
+	Kind    ResourceKind
+	// Folder is the folder containing the file or datastore, relative to the root of its
+	// kind, and always starting with a /.  Folder is / for the root itself
+	Folder string
+	// Name is the name of the file or datastore, or empty for the root
+	Name string
+}
+
+// ParsePath parses a freehold file or datastore path.  A trailing slash is ignored
+func ParsePath(p string) (Path, error) {
+	result := Path{}
+
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segments) < 2 {
+		return result, fmt.Errorf("Invalid freehold path %q", p)
+	}
+
+	if !isVersion(segments[0]) {
+		result.App = segments[0]
+		segments = segments[1:]
+	}
+	if len(segments) < 2 || !isVersion(segments[0]) {
+		return result, fmt.Errorf("Invalid freehold path %q: unsupported version", p)
+	}
+	result.Version = segments[0]
+
+	switch ResourceKind(segments[1]) {
+	case KindFile, KindDatastore:
+		result.Kind = ResourceKind(segments[1])
+	default:
+		return result, fmt.Errorf("Invalid freehold path %q: %q is not a file or datastore path",
+			p, segments[1])
+	}
+
+	for _, s := range segments[2:] {
+		if s == "" || s == "." || s == ".." {
+			return result, fmt.Errorf("Invalid freehold path %q", p)
+		}
+	}
+
+	result.Folder, result.Name = splitResource("/" + strings.Join(segments[2:], "/"))
+	return result, nil
+}
+
+// splitResource splits a path relative to the root of a resource kind into its folder and name
+func splitResource(p string) (folder, name string) {
+	p = path.Clean(p)
+	if p == "/" {
+		return "/", ""
+	}
+	return path.Dir(p), path.Base(p)
+}
+
+// IsRoot is whether or not the path is the root folder of its kind, such as /v1/file/
+func (p Path) IsRoot() bool {
+	return p.Name == ""
+}
+
+// Resource is the path relative to the root of its kind, such as /folder/test.txt
+func (p Path) Resource() string {
+	return path.Join(p.Folder, p.Name)
+}
+
+func (p Path) prefix(kind string) string {
+	if p.App != "" {
+		return "/" + path.Join(p.App, p.Version, kind)
+	}
+	return "/" + path.Join(p.Version, kind)
+}
+
+// String returns the full freehold path.  The root of a kind has a trailing slash
+func (p Path) String() string {
+	if p.IsRoot() {
+		return p.prefix(string(p.Kind)) + "/"
+	}
+	return p.prefix(string(p.Kind)) + p.Resource()
+}
+
+// PropertiesPath returns the path used to retrieve the properties of the file or datastore.
+// Add a trailing slash to list the properties of a folder's children instead
+func (p Path) PropertiesPath() string {
+	if p.IsRoot() {
+		return p.prefix(path.Join("properties", string(p.Kind)))
+	}
+	return p.prefix(path.Join("properties", string(p.Kind))) + p.Resource()
+}
+
+// Join returns the path with the elements appended.  As with path.Join, .. elements are
+// resolved, but the result never leaves the root of the path's kind
+func (p Path) Join(elem ...string) Path {
+	p.Folder, p.Name = splitResource(path.Join(append([]string{p.Resource()}, elem...)...))
+	return p
+}
+
+// Parent returns the folder containing the path.  The parent of the root is the root
+func (p Path) Parent() Path {
+	p.Folder, p.Name = splitResource(p.Folder)
+	return p
+}
+
+// Contains is whether or not the target is the path, or inside of it
+func (p Path) Contains(target Path) bool {
+	_, err := p.Rel(target)
+	return err == nil
+}
+
+// Rel returns the slash separated path of target relative to p, or "." if they are the
+// same.  Target must be p or inside of it
+func (p Path) Rel(target Path) (string, error) {
+	if p.App != target.App || p.Version != target.Version || p.Kind != target.Kind {
+		return "", fmt.Errorf("%s is not within %s", target, p)
+	}
+
+	base, t := p.Resource(), target.Resource()
+	if base == t {
+		return ".", nil
+	}
+	if base != "/" {
+		base += "/"
+	}
+	if !strings.HasPrefix(t, base) {
+		return "", fmt.Errorf("%s is not within %s", target, p)
+	}
+	return strings.TrimPrefix(t, base), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import "testing"
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		in         string
+		expected   Path
+		str        string
+		properties string
+	}{
+		{"/v1/file/test.txt", Path{Version: "v1", Kind: KindFile, Folder: "/", Name: "test.txt"},
+			"/v1/file/test.txt", "/v1/properties/file/test.txt"},
+		{"/v1/file/", Path{Version: "v1", Kind: KindFile, Folder: "/"},
+			"/v1/file/", "/v1/properties/file"},
+		{"v1/file/folder/sub/", Path{Version: "v1", Kind: KindFile, Folder: "/folder", Name: "sub"},
+			"/v1/file/folder/sub", "/v1/properties/file/folder/sub"},
+		{"/testapp/v1/datastore/data/test.ds",
+			Path{App: "testapp", Version: "v1", Kind: KindDatastore, Folder: "/data", Name: "test.ds"},
+			"/testapp/v1/datastore/data/test.ds", "/testapp/v1/properties/datastore/data/test.ds"},
+	}
+
+	for _, test := range tests {
+		p, err := ParsePath(test.in)
+		if err != nil {
+			t.Errorf("Error parsing %s: %s", test.in, err)
+			continue
+		}
+		if p != test.expected {
+			t.Errorf("Parsed %s incorrectly. Expected %+v got %+v", test.in, test.expected, p)
+		}
+		if p.String() != test.str {
+			t.Errorf("Expected %s got %s", test.str, p.String())
+		}
+		if p.PropertiesPath() != test.properties {
+			t.Errorf("Expected properties path %s got %s", test.properties, p.PropertiesPath())
+		}
+	}
+
+	for _, bad := range []string{"", "/", "/v1", "/v2/file/test.txt", "/app/file/test.txt",
+		"/v1/properties/file/test.txt", "/v1/file/a/../b", "/v1/file/a//b"} {
+		_, err := ParsePath(bad)
+		if err == nil {
+			t.Errorf("Parsing invalid path %q did not fail", bad)
+		}
+	}
+}
+
+func TestPathOperations(t *testing.T) {
+	root, err := ParsePath("/v1/file/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := root.Join("folder", "sub", "test.txt")
+	if p.String() != "/v1/file/folder/sub/test.txt" {
+		t.Errorf("Join incorrect. Got %s", p)
+	}
+	if p.Parent().String() != "/v1/file/folder/sub" {
+		t.Errorf("Parent incorrect. Got %s", p.Parent())
+	}
+	if !root.Parent().IsRoot() {
+		t.Errorf("Parent of the root is not the root")
+	}
+	if escaped := root.Join("..", "..", "test.txt"); escaped.String() != "/v1/file/test.txt" {
+		t.Errorf("Join left the root. Got %s", escaped)
+	}
+
+	folder := root.Join("folder")
+	rel, err := folder.Rel(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel != "sub/test.txt" {
+		t.Errorf("Rel incorrect. Got %s", rel)
+	}
+	rel, err = root.Rel(p)
+	if err != nil || rel != "folder/sub/test.txt" {
+		t.Errorf("Rel from root incorrect. Got %s, %v", rel, err)
+	}
+	if rel, _ = p.Rel(p); rel != "." {
+		t.Errorf("Rel of same path incorrect. Got %s", rel)
+	}
+
+	if folder.Contains(root.Join("folder2")) {
+		t.Errorf("Folder contains sibling with the same prefix")
+	}
+	ds, _ := ParsePath("/v1/datastore/folder/sub/test.txt")
+	if folder.Contains(ds) {
+		t.Errorf("File folder contains datastore path")
+	}
+	if !folder.Contains(folder) {
+		t.Errorf("Folder doesn't contain itself")
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Access is the level of access a role has to a file or datastore
+type Access uint8
+
+// Access flags, which can be combined
+const (
+	Read Access = 1 << iota
+	Write
+
+	NoAccess  Access = 0
+	ReadWrite        = Read | Write
+)
+
+// ParseAccess parses a freehold access string, such as "r", "w", "rw", or "" for no access
+func ParseAccess(s string) (Access, error) {
+	var a Access
+	for _, c := range s {
+		var flag Access
+		switch c {
+		case 'r':
+			flag = Read
+		case 'w':
+			flag = Write
+		default:
+			return NoAccess, fmt.Errorf("Invalid access %q", s)
+		}
+		if a&flag != 0 {
+			return NoAccess, fmt.Errorf("Invalid access %q", s)
+		}
+		a |= flag
+	}
+	return a, nil
+}
+
+// String formats the access as freehold expects it
+func (a Access) String() string {
+	s := ""
+	if a&Read != 0 {
+		s += "r"
+	}
+	if a&Write != 0 {
+		s += "w"
+	}
+	return s
+}
+
+// Role is who a permission applies to
+type Role int
+
+// Freehold permission roles.  RolePublic is anyone, including anonymous users, RoleFriend
+// is any logged in user, and RolePrivate is the owner
+const (
+	RolePublic Role = iota
+	RoleFriend
+	RolePrivate
+)
+
+func (r Role) String() string {
+	switch r {
+	case RolePublic:
+		return "public"
+	case RoleFriend:
+		return "friend"
+	case RolePrivate:
+		return "private"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// PrivatePermission returns a permission which only allows the owner access
+func PrivatePermission() *Permission {
+	return &Permission{Private: ReadWrite.String()}
+}
+
+// PublicReadPermission returns a permission which allows anyone to read, and only
+// the owner to write
+func PublicReadPermission() *Permission {
+	return &Permission{Public: Read.String(), Friend: Read.String(), Private: ReadWrite.String()}
+}
+
+// FriendsReadWritePermission returns a permission which allows any logged in user to
+// read and write
+func FriendsReadWritePermission() *Permission {
+	return &Permission{Friend: ReadWrite.String(), Private: ReadWrite.String()}
+}
+
+func (prm *Permission) field(role Role) (*string, error) {
+	switch role {
+	case RolePublic:
+		return &prm.Public, nil
+	case RoleFriend:
+		return &prm.Friend, nil
+	case RolePrivate:
+		return &prm.Private, nil
+	}
+	return nil, fmt.Errorf("Invalid role %s", role)
+}
+
+// Access returns the access explicitly granted to the role
+func (prm *Permission) Access(role Role) (Access, error) {
+	f, err := prm.field(role)
+	if err != nil {
+		return NoAccess, err
+	}
+	return ParseAccess(*f)
+}
+
+// SetAccess sets the access granted to the role
+func (prm *Permission) SetAccess(role Role, access Access) error {
+	f, err := prm.field(role)
+	if err != nil {
+		return err
+	}
+	if access&^ReadWrite != 0 {
+		return fmt.Errorf("Invalid access %d", access)
+	}
+	*f = access.String()
+	return nil
+}
+
+// Validate checks that the public, friend, and private access are all valid
+func (prm *Permission) Validate() error {
+	if prm == nil {
+		return errors.New("Permission is nil")
+	}
+	for _, role := range []Role{RolePublic, RoleFriend, RolePrivate} {
+		_, err := prm.Access(role)
+		if err != nil {
+			return fmt.Errorf("Invalid %s permission: %s", role, err)
+		}
+	}
+	return nil
+}
+
+// Allows returns whether or not the role has the access requested.  Access granted to
+// public also applies to friends and the owner, and access granted to friends also applies
+// to the owner.  Invalid permissions allow nothing
+func (prm *Permission) Allows(role Role, access Access) bool {
+	if role < RolePublic || role > RolePrivate || prm.Validate() != nil {
+		return false
+	}
+	var granted Access
+	for r := RolePublic; r <= role; r++ {
+		a, _ := prm.Access(r)
+		granted |= a
+	}
+	return granted&access == access
+}
+
+// withoutOwner returns a copy of the permission with the owner cleared, for applying
+// to another file
+func (prm *Permission) withoutOwner() *Permission {
+	return &Permission{
+		Public:  prm.Public,
+		Friend:  prm.Friend,
+		Private: prm.Private,
+	}
+}
+
+// PermissionRule sets the permissions of newly created files, folders, and datastores
+// whose path matches Pattern.  Pattern uses path.Match syntax, and is matched against the
+// full path of the new item, and each of its parent folders, so a rule for
+// /v1/file/public applies to everything created under that folder
+type PermissionRule struct {
+	Pattern    string
+	Permission *Permission
+}
+
+// validate checks the rule's pattern and permission
+func (r PermissionRule) validate() error {
+	_, err := path.Match(r.Pattern, "/")
+	if err == nil {
+		err = r.Permission.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid permission rule %q: %s", r.Pattern, err)
+	}
+	return nil
+}
+
+func (r PermissionRule) matches(p string) bool {
+	p = strings.TrimSuffix(p, "/")
+	for p != "/" && p != "." {
+		if ok, _ := path.Match(r.Pattern, p); ok {
+			return true
+		}
+		p = path.Dir(p)
+	}
+	return false
+}
+
+// newPermission returns the permissions to apply to a newly created item, or nil if
+// the freehold defaults should be left as is.  The first matching PermissionRule wins,
+// otherwise, if InheritPermissions is set, the parent folder's permissions are used.
+// parent is retrieved if it isn't passed in.  It's called before the item is created, so
+// invalid PermissionRules fail before anything is written
+func (c *Client) newPermission(itemPath string, parent *Property) (*Permission, error) {
+	for _, r := range c.PermissionRules {
+		err := r.validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range c.PermissionRules {
+		if r.matches(itemPath) {
+			return r.Permission, nil
+		}
+	}
+
+	if !c.InheritPermissions {
+		return nil, nil
+	}
+
+	if parent == nil {
+		dir, err := c.GetFile(path.Dir(strings.TrimSuffix(itemPath, "/")))
+		if err != nil {
+			return nil, err
+		}
+		parent = &dir.Property
+	}
+
+	if parent.Permissions == nil {
+		return nil, nil
+	}
+
+	// ownership is never inherited
+	return parent.Permissions.withoutOwner(), nil
+}
+
+// applyNewPermission sets the permissions returned from newPermission on the newly created
+// item.  If they can't be set, the item is removed rather than left with the wrong permissions
+func applyNewPermission(p *Property, prm *Permission) error {
+	if prm == nil {
+		return nil
+	}
+	err := p.SetPermission(prm)
+	if err != nil {
+		p.remove()
+		return err
+	}
+	p.Permissions = prm
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInheritPermissions(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addDir("/v1/file/testing")
+	m.node("/v1/file/testing").perm = &Permission{Owner: username, Public: "r", Private: "rw"}
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// new items are left alone by default
+	_, err = client.UploadFromReader("default.txt", strings.NewReader("data"), 4, time.Now(), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prm := m.node("/v1/file/testing/default.txt").perm; prm != nil {
+		t.Errorf("Permissions were set without inheritance enabled: %v", prm)
+	}
+
+	client.InheritPermissions = true
+	client.PermissionRules = []PermissionRule{
+		{Pattern: "/v1/file/testing/*.secret", Permission: &Permission{Private: "rw"}},
+		{Pattern: "/v1/file/testing/shared", Permission: &Permission{Friend: "rw", Private: "rw"}},
+	}
+
+	f, err := client.UploadFromReader("inherited.txt", strings.NewReader("data"), 4, time.Now(), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Permissions.Public != "r" || f.Permissions.Private != "rw" {
+		t.Errorf("File did not inherit permissions. Got %+v", f.Permissions)
+	}
+	if prm := m.node("/v1/file/testing/inherited.txt").perm; prm.Owner != "" {
+		t.Errorf("Owner was inherited: %s", prm.Owner)
+	}
+
+	err = client.NewFolder("/v1/file/testing/folder/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prm := m.node("/v1/file/testing/folder").perm; prm == nil || prm.Public != "r" {
+		t.Errorf("Folder did not inherit permissions. Got %+v", prm)
+	}
+
+	f, err = client.UploadFromReader("key.secret", strings.NewReader("data"), 4, time.Now(), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Permissions.Public != "" {
+		t.Errorf("Permission rule was not applied. Got %+v", f.Permissions)
+	}
+
+	// rules matching a parent folder apply to everything created under it
+	err = client.NewFolder("/v1/file/testing/shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.NewFolder("/v1/file/testing/shared/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prm := m.node("/v1/file/testing/shared/sub").perm; prm == nil || prm.Friend != "rw" || prm.Public != "" {
+		t.Errorf("Permission rule was not applied to child folder. Got %+v", prm)
+	}
+
+	// invalid rules fail before anything is created
+	rules := client.PermissionRules
+	client.PermissionRules = append(rules, PermissionRule{Pattern: "/v1/file/testing/*.bad"})
+	_, err = client.UploadFromReader("rule.txt", strings.NewReader("data"), 4, time.Now(), dest)
+	if err == nil || m.node("/v1/file/testing/rule.txt") != nil {
+		t.Errorf("A rule without a permission didn't stop the upload: %v", err)
+	}
+	client.PermissionRules = rules
+
+	// new items are removed rather than left with the wrong permissions
+	m.Lock()
+	m.failPermissions = true
+	m.Unlock()
+	_, err = client.UploadFromReader("failed.txt", strings.NewReader("data"), 4, time.Now(), dest)
+	if err == nil || m.node("/v1/file/testing/failed.txt") != nil {
+		t.Errorf("File whose permissions couldn't be set was left behind: %v", err)
+	}
+	err = client.NewFolder("/v1/file/testing/failed")
+	if err == nil || m.node("/v1/file/testing/failed") != nil {
+		t.Errorf("Folder whose permissions couldn't be set was left behind: %v", err)
+	}
+}
+
+func TestAccess(t *testing.T) {
+	tests := []struct {
+		in     string
+		access Access
+		valid  bool
+	}{
+		{"", NoAccess, true},
+		{"r", Read, true},
+		{"w", Write, true},
+		{"rw", ReadWrite, true},
+		{"wr", ReadWrite, true},
+		{"rr", NoAccess, false},
+		{"rx", NoAccess, false},
+		{"R", NoAccess, false},
+	}
+
+	for _, test := range tests {
+		a, err := ParseAccess(test.in)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseAccess(%q) validity incorrect. Got error: %v", test.in, err)
+		}
+		if a != test.access {
+			t.Errorf("ParseAccess(%q) expected %v got %v", test.in, test.access, a)
+		}
+	}
+
+	if ReadWrite.String() != "rw" || Read.String() != "r" || NoAccess.String() != "" {
+		t.Errorf("Access formatted incorrectly")
+	}
+}
+
+func TestPermissionAllows(t *testing.T) {
+	prm := PublicReadPermission()
+	if !prm.Allows(RolePublic, Read) || prm.Allows(RolePublic, Write) {
+		t.Errorf("Public read permission incorrect for public")
+	}
+	if !prm.Allows(RolePrivate, ReadWrite) {
+		t.Errorf("Public read permission doesn't allow owner to write")
+	}
+
+	prm = FriendsReadWritePermission()
+	if prm.Allows(RolePublic, Read) || !prm.Allows(RoleFriend, ReadWrite) {
+		t.Errorf("Friends read write permission incorrect")
+	}
+
+	prm = &Permission{Public: "r"}
+	if !prm.Allows(RoleFriend, Read) || !prm.Allows(RolePrivate, Read) || prm.Allows(RolePrivate, Write) {
+		t.Errorf("Public access not inherited by other roles")
+	}
+
+	prm = PrivatePermission()
+	if prm.Allows(RoleFriend, Read) || !prm.Allows(RolePrivate, ReadWrite) {
+		t.Errorf("Private permission incorrect")
+	}
+	err := prm.SetAccess(RolePublic, Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prm.Public != "r" {
+		t.Errorf("SetAccess did not set public access. Got %q", prm.Public)
+	}
+
+	prm = &Permission{Public: "read"}
+	if prm.Validate() == nil {
+		t.Errorf("Invalid permission passed validation")
+	}
+	if prm.Allows(RolePrivate, NoAccess) {
+		t.Errorf("Invalid permission allowed access")
+	}
+}
+
+func TestSetPermissionValidates(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addFile("/v1/file/testing.txt", "data", time.Now())
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := client.GetFile("/v1/file/testing.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.SetPermission(&Permission{Public: "rx"})
+	if err == nil {
+		t.Errorf("Invalid permission was sent")
+	}
+	if m.node("/v1/file/testing.txt").perm != nil {
+		t.Errorf("Invalid permission reached the server")
+	}
+
+	err = f.SetPermission(PublicReadPermission())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,7 +5,9 @@
 package freeholdclient
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"path"
@@ -26,6 +28,10 @@ type Property struct {
 	client     *Client
 	readerBody io.ReadCloser
 	modTime    time.Time
+	// dataSize is the size of the decoded data, cached once it's been read from the
+	// transform header
+	dataSize     int64
+	dataSizeRead bool
 }
 
 // Permission is the client side definition of a Freehold Permission
@@ -37,7 +43,23 @@ type Permission struct {
 }
 
 func (p *Property) upload(method string, r io.Reader, size int64, modTime time.Time) error {
+	return p.uploadWithHeader(method, r, size, modTime, nil)
+}
+
+// uploadWithHeader uploads the data from r, adding the passed in headers to the request
+func (p *Property) uploadWithHeader(method string, r io.Reader, size int64, modTime time.Time,
+	header http.Header) error {
 	lr := io.LimitReader(r, size)
+	p.dataSizeRead = false
+
+	if p.transformed() {
+		encoded, encodedSize, cleanup, err := p.client.encode(lr, size)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		lr, size = encoded, encodedSize
+	}
 
 	var res *http.Response
 
@@ -46,8 +68,7 @@ func (p *Property) upload(method string, r io.Reader, size int64, modTime time.T
 
 	done := make(chan error, 1)
 
-	p.client.root.Path = path.Dir(p.URL)
-	uri := p.client.root.String()
+	uri := p.client.fullURL(path.Dir(p.URL))
 
 	go func() {
 		defer pWrite.Close()
@@ -79,8 +100,12 @@ func (p *Property) upload(method string, r io.Reader, size int64, modTime time.T
 		req.Header.Set("Fh-Modified", modTime.Format(time.RFC3339))
 	}
 
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.ContentLength = multipartOverhead + size + int64(len([]byte("file"+p.Name)))
+	req.ContentLength = multipartLength("file", p.Name, size)
 
 	res, err = p.client.hClient.Do(req)
 
@@ -113,6 +138,36 @@ func (p *Property) ModifiedTime() time.Time {
 	return p.modTime
 }
 
+// Stat retrieves the properties of the file, folder, or datastore at the path
+func (c *Client) Stat(filePath string) (*Property, error) {
+	fhPath, err := ParsePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Property{}
+	err = c.doRequest("GET", fhPath.PropertiesPath(), nil, p)
+	if err != nil {
+		return nil, err
+	}
+
+	p.client = c
+
+	return p, nil
+}
+
+// Exists returns whether or not a file, folder, or datastore exists at the path
+func (c *Client) Exists(filePath string) (bool, error) {
+	_, err := c.Stat(filePath)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Children returns the child files / datastores (if any) of the given folder
 // Calling Children on a non-dir file will not error but return
 // an empty slice
@@ -121,13 +176,17 @@ func (p *Property) Children() ([]Property, error) {
 		return []Property{}, nil
 	}
 
-	uri := propertyPath(p.URL)
+	fhPath, err := ParsePath(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	uri := fhPath.PropertiesPath()
 	if !strings.HasSuffix(uri, "/") {
 		uri += "/"
 	}
 
 	var children []Property
-	err := p.client.doRequest("GET", uri, nil, &children)
+	err = p.client.doRequest("GET", uri, nil, &children)
 	if err != nil {
 		return nil, err
 	}
@@ -141,37 +200,94 @@ func (p *Property) Children() ([]Property, error) {
 // FullURL returns the full url of the file / datstore including the
 // root of the freehold instance
 func (p *Property) FullURL() string {
-	p.client.root.Path = p.URL
-	return p.client.root.String()
+	return p.client.fullURL(p.URL)
 }
 
 // Reads data from the freehold instance on the given file or datastore (GET file data)
 // Close() needs to be called when read is completed
 func (p *Property) Read(b []byte) (n int, err error) {
 	if p.readerBody == nil {
-		req, err := http.NewRequest("GET", p.FullURL(), nil)
+		body, err := p.openRange(0)
 		if err != nil {
 			return 0, err
 		}
+		p.readerBody = body
+	}
+	return p.readerBody.Read(b)
+}
 
-		req.SetBasicAuth(p.client.username, p.client.pass)
-		res, err := p.client.hClient.Do(req)
-
+// openRange opens the file / datastore's data for reading starting at offset.  If the
+// freehold instance doesn't honor the range request, the leading bytes are discarded.
+// Files written with transforms are decoded, and the offset applies to the decoded data
+func (p *Property) openRange(offset int64) (io.ReadCloser, error) {
+	if p.transformed() {
+		body, err := p.openRaw(0)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
-
-		err = isError(p.FullURL(), res.StatusCode, nil)
+		body, err = p.client.decode(body)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
+		if offset > 0 {
+			_, err = io.CopyN(ioutil.Discard, body, offset)
+			if err != nil && err != io.EOF {
+				body.Close()
+				return nil, err
+			}
+		}
+		return body, nil
+	}
+	return p.openRaw(offset)
+}
+
+// openRaw opens the data as it's stored on the freehold instance starting at offset
+func (p *Property) openRaw(offset int64) (io.ReadCloser, error) {
+	return p.openRawRange(offset, 0)
+}
+
+// openRawRange opens at most length bytes of the data as it's stored on the freehold
+// instance starting at offset, or the rest of the data if length is 0
+func (p *Property) openRawRange(offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", p.FullURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(p.client.username, p.client.pass)
+	if length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	res, err := p.client.hClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		res.Body.Close()
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+
+	err = isError(p.FullURL(), res.StatusCode, nil)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
 
-		if res != nil {
-			p.readerBody = res.Body
+	if offset > 0 && res.StatusCode != http.StatusPartialContent {
+		_, err = io.CopyN(ioutil.Discard, res.Body, offset)
+		if err != nil && err != io.EOF {
+			res.Body.Close()
+			return nil, err
 		}
+	}
 
+	if length > 0 {
+		return &readCloser{io.LimitReader(res.Body, length), res.Body}, nil
 	}
-	return p.readerBody.Read(b)
+	return res.Body, nil
 }
 
 // Close closes the open reader
@@ -184,13 +300,27 @@ func (p *Property) Close() error {
 	return nil
 }
 
-// Delete deletes a file / datastore
+// Delete deletes a file / datastore.  If the client's UseTrash is set, files and folders are
+// moved to the trash instead
 func (p *Property) Delete() error {
+	if p.client.trashes(p) {
+		_, err := p.client.Trash().put(p)
+		return err
+	}
+	return p.remove()
+}
+
+// remove permanently deletes the file / datastore
+func (p *Property) remove() error {
 	return p.client.doRequest("DELETE", p.URL, nil, nil)
 }
 
 // SetPermission sets the current file / datastore's permissions to those
-// passed in
+// passed in.  The permissions are validated before they are sent
 func (p *Property) SetPermission(prm *Permission) error {
+	err := prm.Validate()
+	if err != nil {
+		return err
+	}
 	return p.client.doRequest("PUT", p.URL, map[string]*Permission{"permissions": prm}, nil)
 }
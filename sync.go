@@ -0,0 +1,566 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSyncStateFile is the name of the file, stored in the root of the local directory,
+// that records the state of each path as of the last sync
+const DefaultSyncStateFile = ".freehold-sync"
+
+// SyncOptions are the options used when syncing a local directory with a freehold folder
+type SyncOptions struct {
+	// StateFile is the path of the local sync state file, defaults to DefaultSyncStateFile
+	// in the root of the local directory.
+	StateFile string
+	// DryRun builds the sync plan without changing anything locally or on the freehold instance
+	DryRun bool
+}
+
+// SyncAction is a single type of change made during a sync
+type SyncAction int
+
+// Types of sync actions
+const (
+	SyncUpload       SyncAction = iota // local file or folder is created or updated on the freehold instance
+	SyncDownload                       // remote file or folder is created or updated locally
+	SyncDeleteLocal                    // path was deleted remotely, and is deleted locally
+	SyncDeleteRemote                   // path was deleted locally, and is deleted remotely
+	SyncRenameLocal                    // path was renamed remotely, and is renamed locally
+	SyncRenameRemote                   // path was renamed locally, and is renamed remotely
+	SyncConflict                       // path was changed on both sides, both copies are kept
+)
+
+func (a SyncAction) String() string {
+	switch a {
+	case SyncUpload:
+		return "upload"
+	case SyncDownload:
+		return "download"
+	case SyncDeleteLocal:
+		return "delete local"
+	case SyncDeleteRemote:
+		return "delete remote"
+	case SyncRenameLocal:
+		return "rename local"
+	case SyncRenameRemote:
+		return "rename remote"
+	case SyncConflict:
+		return "conflict"
+	}
+	return "unknown"
+}
+
+// SyncOp is a single change in a sync plan.  Paths are slash separated and
+// relative to the root of the sync
+type SyncOp struct {
+	Action SyncAction `json:"action"`
+	Path   string     `json:"path"`
+	// To is the new path of a rename, or the name the local copy of a conflict
+	// is kept under
+	To    string `json:"to,omitempty"`
+	IsDir bool   `json:"isDir,omitempty"`
+	Err   error  `json:"-"`
+
+	local  *syncEntry
+	remote *syncEntry
+}
+
+func (o *SyncOp) String() string {
+	s := fmt.Sprintf("%-13s %s", o.Action, o.Path)
+	if o.IsDir {
+		s += "/"
+	}
+	if o.To != "" {
+		s += " -> " + o.To
+	}
+	if o.Err != nil {
+		s += " (failed: " + o.Err.Error() + ")"
+	}
+	return s
+}
+
+// SyncPlan is the list of changes needed to bring a local directory and
+// a freehold folder in sync
+type SyncPlan struct {
+	Ops []*SyncOp `json:"ops"`
+}
+
+// String returns the plan in a human readable form, one change per line
+func (p *SyncPlan) String() string {
+	var b bytes.Buffer
+	for _, op := range p.Ops {
+		b.WriteString(op.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// syncEntry is the state of a single path on one side of a sync, or as of the
+// last sync
+type syncEntry struct {
+	Size     int64 `json:"size"`
+	Modified int64 `json:"modified"`
+	IsDir    bool  `json:"isDir,omitempty"`
+
+	file *File
+}
+
+func (e *syncEntry) matches(other *syncEntry) bool {
+	if e == nil || other == nil {
+		return e == other
+	}
+	if e.IsDir || other.IsDir {
+		return e.IsDir == other.IsDir
+	}
+	return e.Size == other.Size && e.Modified == other.Modified
+}
+
+// Sync runs a two way sync between the folder and the local directory. Creations,
+// modifications, deletions and renames on either side are applied to the other,
+// based on the state recorded during the previous sync.  When a file is changed on
+// both sides, the remote version is kept under the original name, and the local
+// version is kept on both sides under a conflict name.  If a file is created on the freehold
+// instance while the sync is uploading a new file to the same path, the client's OnConflict
+// strategy decides the outcome, so with the default ConflictFail the upload fails.
+// The plan is returned with the outcome of each operation, and any failures are
+// returned together as PathErrors
+func (f *File) Sync(localDir string, opts *SyncOptions) (*SyncPlan, error) {
+	if !f.IsDir {
+		return nil, errors.New("File is not a directory.")
+	}
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+
+	s := &syncer{
+		root:       f,
+		localDir:   localDir,
+		stateFile:  opts.StateFile,
+		local:      make(map[string]*syncEntry),
+		remote:     make(map[string]*syncEntry),
+		remoteDirs: make(map[string]*File),
+	}
+	if s.stateFile == "" {
+		s.stateFile = filepath.Join(localDir, DefaultSyncStateFile)
+	}
+
+	err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+	err = s.scanLocal()
+	if err != nil {
+		return nil, err
+	}
+	err = s.scanRemote()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := s.plan()
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	var errs PathErrors
+	for _, op := range plan.Ops {
+		op.Err = s.apply(op)
+		if op.Err != nil {
+			errs = append(errs, &PathError{Path: op.Path, Err: op.Err})
+		}
+	}
+
+	err = s.saveState(plan)
+	if err != nil {
+		return plan, err
+	}
+
+	if len(errs) != 0 {
+		return plan, errs
+	}
+	return plan, nil
+}
+
+type syncer struct {
+	root       *File
+	localDir   string
+	stateFile  string
+	state      map[string]*syncEntry
+	local      map[string]*syncEntry
+	remote     map[string]*syncEntry
+	remoteDirs map[string]*File
+}
+
+func (s *syncer) loadState() error {
+	s.state = make(map[string]*syncEntry)
+	data, err := ioutil.ReadFile(s.stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, &s.state)
+	if err != nil {
+		return fmt.Errorf("Error reading sync state file %s: %s", s.stateFile, err)
+	}
+	return nil
+}
+
+// saveState records the outcome of the plan, so the next sync can tell which
+// side a path changed on
+func (s *syncer) saveState(plan *SyncPlan) error {
+	state := make(map[string]*syncEntry)
+	for p, l := range s.local {
+		if r, ok := s.remote[p]; ok && l.matches(r) {
+			state[p] = l
+		}
+	}
+
+	for _, op := range plan.Ops {
+		if op.Err != nil {
+			if old, ok := s.state[op.Path]; ok {
+				state[op.Path] = old
+			}
+			continue
+		}
+		switch op.Action {
+		case SyncUpload:
+			state[op.Path] = op.local
+		case SyncDownload:
+			state[op.Path] = op.remote
+		case SyncDeleteLocal, SyncDeleteRemote:
+			delete(state, op.Path)
+		case SyncRenameLocal, SyncRenameRemote:
+			delete(state, op.Path)
+			state[op.To] = s.state[op.Path]
+		case SyncConflict:
+			state[op.Path] = op.remote
+			state[op.To] = op.local
+		}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.stateFile + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.stateFile)
+}
+
+func (s *syncer) scanLocal() error {
+	return filepath.Walk(s.localDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == s.localDir || p == s.stateFile || p == s.stateFile+".tmp" {
+			return nil
+		}
+		rel, err := filepath.Rel(s.localDir, p)
+		if err != nil {
+			return err
+		}
+		e := &syncEntry{IsDir: info.IsDir()}
+		if !e.IsDir {
+			e.Size = info.Size()
+			e.Modified = info.ModTime().Unix()
+		}
+		s.local[filepath.ToSlash(rel)] = e
+		return nil
+	})
+}
+
+func (s *syncer) scanRemote() error {
+	root := strings.TrimSuffix(s.root.URL, "/")
+	s.remoteDirs[""] = s.root
+
+	return s.root.client.Walk(root, func(p string, prop *Property, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		if rel == "" {
+			return nil
+		}
+
+		e := &syncEntry{IsDir: prop.IsDir, file: &File{*prop}}
+		if prop.IsDir {
+			s.remoteDirs[rel] = e.file
+		} else {
+			// sizes are compared with local files, so they're the size of the decoded data
+			e.Size, err = prop.DataSize()
+			if err != nil {
+				return &PathError{Path: p, Err: err}
+			}
+			e.Modified = prop.ModifiedTime().Unix()
+		}
+		s.remote[rel] = e
+		return nil
+	})
+}
+
+// plan compares each side against the last sync state and builds the list of
+// operations needed to bring both sides back in sync
+func (s *syncer) plan() *SyncPlan {
+	paths := make(map[string]struct{})
+	for _, m := range []map[string]*syncEntry{s.local, s.remote, s.state} {
+		for p := range m {
+			paths[p] = struct{}{}
+		}
+	}
+
+	var ops []*SyncOp
+	for p := range paths {
+		l, r, st := s.local[p], s.remote[p], s.state[p]
+		op := &SyncOp{Path: p, local: l, remote: r}
+
+		switch {
+		case l == nil && r == nil:
+			continue
+		case l != nil && r != nil:
+			if l.IsDir != r.IsDir {
+				op.Err = errors.New("Path is a folder on one side and a file on the other")
+				break
+			}
+			if l.matches(r) {
+				continue
+			}
+			localChanged, remoteChanged := !l.matches(st), !r.matches(st)
+			switch {
+			case localChanged && !remoteChanged:
+				op.Action = SyncUpload
+			case remoteChanged && !localChanged:
+				op.Action = SyncDownload
+			default:
+				op.Action = SyncConflict
+				op.To = conflictName(p, time.Now())
+			}
+		case l != nil:
+			op.Action = SyncUpload
+			if st != nil && l.matches(st) {
+				op.Action = SyncDeleteLocal
+			}
+		case r != nil:
+			op.Action = SyncDownload
+			if st != nil && r.matches(st) {
+				op.Action = SyncDeleteRemote
+			}
+		}
+		op.IsDir = (l != nil && l.IsDir) || (r != nil && r.IsDir)
+		ops = append(ops, op)
+	}
+
+	ops = s.findRenames(ops)
+	ops = s.keepNonEmptyDirs(ops)
+
+	sort.Sort(syncOrder(ops))
+	return &SyncPlan{Ops: ops}
+}
+
+// findRenames pairs a file deleted on one side with a new file with the same size
+// and modified time on that side, and replaces them with a single rename
+func (s *syncer) findRenames(ops []*SyncOp) []*SyncOp {
+	removed := make(map[*SyncOp]bool)
+	for _, del := range ops {
+		if del.IsDir || (del.Action != SyncDeleteLocal && del.Action != SyncDeleteRemote) {
+			continue
+		}
+		last := s.state[del.Path]
+
+		for _, add := range ops {
+			if removed[add] || add.IsDir || add.Err != nil || s.state[add.Path] != nil {
+				continue
+			}
+			switch {
+			case del.Action == SyncDeleteRemote && add.Action == SyncUpload && add.local.matches(last):
+				del.Action = SyncRenameRemote
+			case del.Action == SyncDeleteLocal && add.Action == SyncDownload && add.remote.matches(last):
+				del.Action = SyncRenameLocal
+			default:
+				continue
+			}
+			del.To = add.Path
+			removed[add] = true
+			break
+		}
+	}
+
+	result := ops[:0]
+	for _, op := range ops {
+		if !removed[op] {
+			result = append(result, op)
+		}
+	}
+	return result
+}
+
+// keepNonEmptyDirs replaces the delete of a folder with a re-create when
+// something is still going to end up inside of it
+func (s *syncer) keepNonEmptyDirs(ops []*SyncOp) []*SyncOp {
+	for _, dir := range ops {
+		if !dir.IsDir || (dir.Action != SyncDeleteLocal && dir.Action != SyncDeleteRemote) {
+			continue
+		}
+		prefix := dir.Path + "/"
+		for _, op := range ops {
+			inside := strings.HasPrefix(op.Path, prefix) &&
+				op.Action != SyncDeleteLocal && op.Action != SyncDeleteRemote &&
+				op.Action != SyncRenameLocal && op.Action != SyncRenameRemote
+			if strings.HasPrefix(op.To, prefix) || inside || (op.Err != nil && strings.HasPrefix(op.Path, prefix)) {
+				if dir.Action == SyncDeleteLocal {
+					dir.Action = SyncUpload
+				} else {
+					dir.Action = SyncDownload
+				}
+				break
+			}
+		}
+	}
+	return ops
+}
+
+// syncOrder sorts operations so folders are created before their contents, and
+// deleted after them
+type syncOrder []*SyncOp
+
+func (o syncOrder) Len() int      { return len(o) }
+func (o syncOrder) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o syncOrder) Less(i, j int) bool {
+	pi, pj := o[i].priority(), o[j].priority()
+	if pi != pj {
+		return pi < pj
+	}
+	if pi == 4 {
+		return o[i].Path > o[j].Path
+	}
+	return o[i].Path < o[j].Path
+}
+
+func (o *SyncOp) priority() int {
+	deleting := o.Action == SyncDeleteLocal || o.Action == SyncDeleteRemote
+	switch {
+	case o.IsDir && !deleting:
+		return 0
+	case o.Action == SyncRenameLocal || o.Action == SyncRenameRemote:
+		return 1
+	case !deleting:
+		return 2
+	case !o.IsDir:
+		return 3
+	}
+	return 4
+}
+
+func (s *syncer) localPath(rel string) string {
+	return filepath.Join(s.localDir, filepath.FromSlash(rel))
+}
+
+func (s *syncer) remotePath(rel string) string {
+	return path.Join(s.root.URL, rel)
+}
+
+// remoteDir returns the remote folder that the relative path is stored in
+func (s *syncer) remoteDir(rel string) (*File, error) {
+	dir := path.Dir(rel)
+	if dir == "." {
+		dir = ""
+	}
+	if f, ok := s.remoteDirs[dir]; ok {
+		return f, nil
+	}
+	f, err := s.root.client.GetFile(s.remotePath(dir))
+	if err != nil {
+		return nil, err
+	}
+	s.remoteDirs[dir] = f
+	return f, nil
+}
+
+func (s *syncer) apply(op *SyncOp) error {
+	if op.Err != nil {
+		return op.Err
+	}
+	switch op.Action {
+	case SyncUpload:
+		if op.IsDir {
+			return s.root.client.NewFolder(s.remotePath(op.Path) + "/")
+		}
+		return s.upload(op.Path, op.Path, op.local)
+	case SyncDownload:
+		if op.IsDir {
+			return os.MkdirAll(s.localPath(op.Path), 0755)
+		}
+		return downloadFile(op.remote.file, s.localPath(op.Path))
+	case SyncDeleteLocal:
+		return os.Remove(s.localPath(op.Path))
+	case SyncDeleteRemote:
+		return op.remote.file.Delete()
+	case SyncRenameLocal:
+		err := os.MkdirAll(filepath.Dir(s.localPath(op.To)), 0755)
+		if err != nil {
+			return err
+		}
+		return os.Rename(s.localPath(op.Path), s.localPath(op.To))
+	case SyncRenameRemote:
+		return op.remote.file.Move(s.remotePath(op.To))
+	case SyncConflict:
+		err := os.Rename(s.localPath(op.Path), s.localPath(op.To))
+		if err != nil {
+			return err
+		}
+		err = s.upload(op.To, op.To, op.local)
+		if err != nil {
+			return err
+		}
+		return downloadFile(op.remote.file, s.localPath(op.Path))
+	}
+	return nil
+}
+
+// upload uploads the local file, updating the remote file if it already exists
+func (s *syncer) upload(localRel, remoteRel string, local *syncEntry) error {
+	file, err := os.Open(s.localPath(localRel))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	modTime := time.Unix(local.Modified, 0)
+
+	if r, ok := s.remote[remoteRel]; ok && !r.IsDir {
+		return r.file.upload("PUT", file, local.Size, modTime)
+	}
+
+	dest, err := s.remoteDir(remoteRel)
+	if err != nil {
+		return err
+	}
+	_, err = s.root.client.UploadFromReader(path.Base(remoteRel), file, local.Size, modTime, dest)
+	return err
+}
+
+// conflictName is the name the local copy of a conflicted file is kept under
+func conflictName(p string, when time.Time) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + ".sync-conflict-" + when.Format("20060102-150405") + ext
+}
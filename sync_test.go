@@ -0,0 +1,161 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSync(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/remote.txt", "remote file", modified)
+	m.addFile("/v1/file/testing/renamed.txt", "renamed remotely", modified)
+	m.addFile("/v1/file/testing/deleted.txt", "deleted locally", modified)
+	m.addFile("/v1/file/testing/conflict.txt", "conflict", modified)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "freeholdclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeLocal := func(name, data string) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		err := ioutil.WriteFile(p, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(p, modified, modified)
+	}
+	readLocal := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	writeLocal("local/local.txt", "local file")
+
+	f, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := f.Sync(dir, &SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Ops) != 6 {
+		t.Fatalf("Expected 6 operations in the plan got %d:\n%s", len(plan.Ops), plan)
+	}
+	if readLocal("remote.txt") != "" {
+		t.Fatalf("Dry run downloaded a file")
+	}
+
+	_, err = f.Sync(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if readLocal("remote.txt") != "remote file" {
+		t.Errorf("Remote file was not downloaded")
+	}
+	if n := m.node("/v1/file/testing/local/local.txt"); n == nil || string(n.data) != "local file" {
+		t.Errorf("Local file was not uploaded")
+	}
+
+	plan, err = f.Sync(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Ops) != 0 {
+		t.Fatalf("Expected no changes after sync got:\n%s", plan)
+	}
+
+	// changes on both sides
+	later := modified.Add(time.Hour)
+	m.Lock()
+	m.nodes["/v1/file/testing/moved.txt"] = m.nodes["/v1/file/testing/renamed.txt"]
+	delete(m.nodes, "/v1/file/testing/renamed.txt")
+	m.Unlock()
+	m.addFile("/v1/file/testing/remote.txt", "remote file updated", later)
+	m.addFile("/v1/file/testing/conflict.txt", "conflict remote", later)
+	os.Remove(filepath.Join(dir, "deleted.txt"))
+	writeLocal("conflict.txt", "conflict local")
+	os.Rename(filepath.Join(dir, "local", "local.txt"), filepath.Join(dir, "local", "moved.txt"))
+
+	plan, err = f.Sync(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := make(map[string]SyncAction)
+	for _, op := range plan.Ops {
+		actions[op.Path] = op.Action
+	}
+	for p, a := range map[string]SyncAction{
+		"remote.txt":      SyncDownload,
+		"renamed.txt":     SyncRenameLocal,
+		"deleted.txt":     SyncDeleteRemote,
+		"conflict.txt":    SyncConflict,
+		"local/local.txt": SyncRenameRemote,
+	} {
+		if actions[p] != a {
+			t.Errorf("Expected %s for %s got %s:\n%s", a, p, actions[p], plan)
+		}
+	}
+
+	if readLocal("remote.txt") != "remote file updated" {
+		t.Errorf("Updated remote file was not downloaded")
+	}
+	if readLocal("moved.txt") != "renamed remotely" {
+		t.Errorf("Remote rename was not applied locally")
+	}
+	if m.node("/v1/file/testing/deleted.txt") != nil {
+		t.Errorf("Local delete was not applied remotely")
+	}
+	if m.node("/v1/file/testing/local/moved.txt") == nil {
+		t.Errorf("Local rename was not applied remotely")
+	}
+	if readLocal("conflict.txt") != "conflict remote" {
+		t.Errorf("Remote version of the conflict was not kept")
+	}
+
+	found := false
+	m.Lock()
+	for k, n := range m.nodes {
+		if strings.HasPrefix(k, "/v1/file/testing/conflict.sync-conflict-") && string(n.data) == "conflict local" {
+			found = true
+		}
+	}
+	m.Unlock()
+	if !found {
+		t.Errorf("Local version of the conflict was not kept remotely")
+	}
+
+	plan, err = f.Sync(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Ops) != 0 {
+		t.Fatalf("Expected no changes after sync got:\n%s", plan)
+	}
+}
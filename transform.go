@@ -0,0 +1,356 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Transform is a change applied to file data as a streaming stage when it's uploaded,
+// and reversed when it's read, such as compression or encryption
+type Transform interface {
+	// Name identifies the transform in the header of transformed files, and can't
+	// contain commas, semicolons or new lines
+	Name() string
+	// Encode returns a reader which transforms the data read from r.  If the returned reader
+	// is also an io.Closer, it's closed once the upload is finished, whether or not the upload
+	// read all of the data
+	Encode(r io.Reader) (io.Reader, error)
+	// Decode returns a reader which reverses the transform on the data read from r
+	Decode(r io.Reader) (io.Reader, error)
+}
+
+// SizedTransform is a Transform which knows the size of its output before encoding.
+// If every registered transform is a SizedTransform, uploads are streamed directly,
+// otherwise the transformed data is spooled locally to determine its size before upload
+type SizedTransform interface {
+	Transform
+	EncodedSize(size int64) int64
+}
+
+// transformMagic starts the header written to the beginning of transformed files,
+// followed by the comma separated names of the transforms in the order they were
+// applied, a semicolon, the size of the data before it was transformed, and a new line
+const transformMagic = "FHTX1:"
+
+// minTransformHeader is the size of the shortest possible transform header.  Smaller files
+// can't be transformed
+const minTransformHeader = len(transformMagic + "x;0\n")
+
+// maxTransformHeader is the most data read from the start of a file looking for its
+// transform header.  It's also the longest header, and so the longest combined transform
+// names, which can be written
+const maxTransformHeader = 4096
+
+// RegisterTransform adds transforms which are applied, in the order registered, to the data of
+// every file uploaded or updated with this client.  A header recording which transforms were applied
+// is written to the start of each file, and files read with this client which have the header
+// have the transforms reversed automatically.  Transforms aren't applied to datastores.
+//
+// Note that the Size of a transformed file is the size stored on the freehold instance, not
+// the size of the decoded data, which is returned by DataSize.  Any file which starts with the
+// transform header is treated as transformed, so a file written without transforms whose data
+// happens to start with "FHTX1:" can't be read correctly with transforms registered.
+func (c *Client) RegisterTransform(transforms ...Transform) error {
+	for _, t := range transforms {
+		if t.Name() == "" || strings.ContainsAny(t.Name(), ",;\n") {
+			return fmt.Errorf("Invalid transform name %q", t.Name())
+		}
+	}
+	c.transforms = append(c.transforms, transforms...)
+	return nil
+}
+
+// transformed is whether or not registered transforms apply to this property
+func (p *Property) transformed() bool {
+	if len(p.client.transforms) == 0 {
+		return false
+	}
+	fhPath, err := ParsePath(p.URL)
+	return err == nil && fhPath.Kind == KindFile
+}
+
+// DataSize is the size of the file's data as it's read with this client.  For files written
+// with transforms it's the size of the decoded data, which is read from the transform header
+// at the start of the file, otherwise it's the same as Size.  Only the start of the file is
+// requested, and the size is cached on the property, so it's only requested once
+func (p *Property) DataSize() (int64, error) {
+	if p.IsDir || p.Size < int64(minTransformHeader) || !p.transformed() {
+		return p.Size, nil
+	}
+	if p.dataSizeRead {
+		return p.dataSize, nil
+	}
+
+	body, err := p.openRawRange(0, maxTransformHeader)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	br := bufio.NewReaderSize(body, maxTransformHeader)
+	names, size, err := readTransformHeader(br)
+	if err != nil {
+		return 0, err
+	}
+	if names == nil {
+		size = p.Size
+	}
+	p.dataSize, p.dataSizeRead = size, true
+	return size, nil
+}
+
+// encode runs the data through the registered transforms, and returns the encoded data
+// and its size.  size is the size of the data read from r, and is recorded in the header.
+// cleanup must be called once the returned reader is no longer needed
+func (c *Client) encode(r io.Reader, size int64) (encoded io.Reader, encodedSize int64, cleanup func(), err error) {
+	names := make([]string, len(c.transforms))
+	sized := true
+	encodedSize = size
+	counted := &countReader{r: r}
+	encoded = counted
+
+	var closers []io.Closer
+	cleanup = func() {
+		for _, cl := range closers {
+			cl.Close()
+		}
+	}
+
+	for i, t := range c.transforms {
+		names[i] = t.Name()
+		encoded, err = t.Encode(encoded)
+		if err != nil {
+			cleanup()
+			return nil, 0, nil, err
+		}
+		if cl, ok := encoded.(io.Closer); ok {
+			closers = append(closers, cl)
+		}
+		if s, ok := t.(SizedTransform); ok && sized {
+			encodedSize = s.EncodedSize(encodedSize)
+		} else {
+			sized = false
+		}
+	}
+
+	header := fmt.Sprintf("%s%s;%d\n", transformMagic, strings.Join(names, ","), size)
+	if len(header) > maxTransformHeader {
+		cleanup()
+		return nil, 0, nil, errors.New("The names of the registered transforms are too long")
+	}
+	encoded = io.MultiReader(strings.NewReader(header), encoded)
+
+	if sized {
+		return encoded, int64(len(header)) + encodedSize, cleanup, nil
+	}
+
+	s := &spool{threshold: SpoolThreshold}
+	_, err = io.Copy(s, encoded)
+	cleanup()
+	if err == nil && counted.n != size {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		s.cleanup()
+		return nil, 0, nil, err
+	}
+	encoded, err = s.reader()
+	if err != nil {
+		s.cleanup()
+		return nil, 0, nil, err
+	}
+	return encoded, s.size, s.cleanup, nil
+}
+
+// countReader counts the bytes read through it
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// readTransformHeader reads the transform header from the start of the data, and returns the
+// names of the transforms applied and the size of the data before it was transformed.  names
+// is nil if the data has no transform header
+func readTransformHeader(br *bufio.Reader) (names []string, size int64, err error) {
+	prefix, err := br.Peek(len(transformMagic))
+	if err != nil || string(prefix) != transformMagic {
+		return nil, 0, nil
+	}
+
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, 0, errors.New("Invalid transform header")
+	}
+	line = strings.TrimSuffix(strings.TrimPrefix(line, transformMagic), "\n")
+
+	i := strings.LastIndex(line, ";")
+	if i < 0 {
+		return nil, 0, errors.New("Invalid transform header")
+	}
+	size, err = strconv.ParseInt(line[i+1:], 10, 64)
+	if err != nil || size < 0 {
+		return nil, 0, errors.New("Invalid transform header")
+	}
+	return strings.Split(line[:i], ","), size, nil
+}
+
+// decode reverses the transforms recorded in the header of the file data.  Data without
+// a transform header is returned as is
+func (c *Client) decode(body io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+	names, _, err := readTransformHeader(br)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	if names == nil {
+		return &readCloser{br, body}, nil
+	}
+
+	var r io.Reader = br
+	for i := len(names) - 1; i >= 0; i-- {
+		var t Transform
+		for _, registered := range c.transforms {
+			if registered.Name() == names[i] {
+				t = registered
+				break
+			}
+		}
+		if t == nil {
+			body.Close()
+			return nil, fmt.Errorf("File was written with the transform %q which is not registered", names[i])
+		}
+		r, err = t.Decode(r)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+	}
+
+	return &readCloser{r, body}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// GzipTransform compresses file data with gzip
+type GzipTransform struct{}
+
+// Name is gzip
+func (GzipTransform) Name() string { return "gzip" }
+
+// Encode compresses the data read from r.  The returned reader must be closed if it isn't
+// read to the end, to stop the compression
+func (GzipTransform) Encode(r io.Reader) (io.Reader, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, r)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// Decode decompresses the data read from r
+func (GzipTransform) Decode(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+// LineEndingTransform normalizes Windows line endings (\r\n) to \n when uploading.
+// The original line endings are not restored when reading
+type LineEndingTransform struct{}
+
+// Name is lf
+func (LineEndingTransform) Name() string { return "lf" }
+
+// Encode replaces \r\n with \n in the data read from r
+func (LineEndingTransform) Encode(r io.Reader) (io.Reader, error) {
+	return &lfReader{source: bufio.NewReader(r)}, nil
+}
+
+// Decode returns the data as is
+func (LineEndingTransform) Decode(r io.Reader) (io.Reader, error) {
+	return r, nil
+}
+
+type lfReader struct {
+	source *bufio.Reader
+	buf    []byte
+}
+
+func (l *lfReader) Read(b []byte) (int, error) {
+	for len(l.buf) == 0 {
+		line, err := l.source.ReadBytes('\n')
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			line = append(line[:len(line)-2], '\n')
+		}
+		l.buf = line
+		if err != nil && len(l.buf) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(b, l.buf)
+	l.buf = l.buf[n:]
+	return n, nil
+}
+
+// Transform returns the encryption as a SizedTransform, named encrypt, for use with
+// RegisterTransform
+func (e *Encryption) Transform() SizedTransform {
+	return &encryptTransform{e}
+}
+
+type encryptTransform struct {
+	e *Encryption
+}
+
+func (t *encryptTransform) Name() string { return "encrypt" }
+
+func (t *encryptTransform) Encode(r io.Reader) (io.Reader, error) {
+	return t.e.encrypter(r)
+}
+
+func (t *encryptTransform) EncodedSize(size int64) int64 {
+	headerSize := int64(len(encryptMagic) + 4 + 1)
+	for _, k := range t.e.keys {
+		headerSize += 1 + wrappedKeySize
+		if k.passphrase != "" {
+			headerSize += saltSize + 4
+		}
+	}
+	chunkSize := int64(t.e.ChunkSize)
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return headerSize + size + (size/chunkSize+1)*16
+}
+
+func (t *encryptTransform) Decode(r io.Reader) (io.Reader, error) {
+	magic := make([]byte, len(encryptMagic))
+	_, err := io.ReadFull(r, magic)
+	if err != nil || !bytes.Equal(magic, []byte(encryptMagic)) {
+		return nil, errBadEncryptedFile
+	}
+	return t.e.decrypter(r)
+}
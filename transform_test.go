@@ -0,0 +1,305 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransforms(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/plain.txt", "plain file", time.Now())
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.RegisterTransform(LineEndingTransform{}, GzipTransform{}, client.Encryption(key).Transform())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := strings.Repeat("line one\r\nline two\r\n", 50)
+	f, err := client.UploadFromReader("transformed.txt", strings.NewReader(data), int64(len(data)), time.Now(), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := m.node("/v1/file/testing/transformed.txt").data
+	header := fmt.Sprintf("%slf,gzip,encrypt;%d\n", transformMagic, len(data))
+	if !bytes.HasPrefix(stored, []byte(header)) {
+		t.Errorf("Transform header was not written. Got %q", stored[:len(header)])
+	}
+	if bytes.Contains(stored, []byte("line one")) {
+		t.Errorf("File data was not transformed")
+	}
+
+	m.Lock()
+	m.reads = nil
+	m.Unlock()
+	for i := 0; i < 2; i++ {
+		size, err := f.DataSize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != int64(len(data)) {
+			t.Errorf("Expected a data size of %d got %d", len(data), size)
+		}
+	}
+	tiny, err := client.GetFile("/v1/file/testing/plain.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiny.Size = int64(minTransformHeader - 1)
+	_, err = tiny.DataSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Lock()
+	reads := m.reads
+	m.Unlock()
+	if len(reads) != 1 || reads[0] != fmt.Sprintf("bytes=0-%d", maxTransformHeader-1) {
+		t.Errorf("Expected the data size to be read once from the start of the file got %q", reads)
+	}
+
+	result, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(data, "\r\n", "\n", -1)
+	if string(result) != expected {
+		t.Errorf("Transforms were not reversed on read. Got %q", result)
+	}
+
+	// sized transforms are streamed without spooling
+	sized, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sized.RegisterTransform(sized.Encryption(key).Transform())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Update(strings.NewReader("updated"), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest.client = sized
+	_, err = sized.UploadFromReader("sized.txt", strings.NewReader("sized data"), 10, time.Now(), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizedFile, err := sized.GetFile("/v1/file/testing/sized.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = ioutil.ReadAll(sizedFile)
+	sizedFile.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "sized data" {
+		t.Errorf("Sized transform data doesn't match. Got %q", result)
+	}
+
+	// files without a header are read as is
+	plain, err := client.GetFile("/v1/file/testing/plain.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = ioutil.ReadAll(plain)
+	plain.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "plain file" {
+		t.Errorf("Untransformed file doesn't match. Got %q", result)
+	}
+
+	// reading a file with a transform that isn't registered fails
+	f, err = sized.GetFile("/v1/file/testing/transformed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(f)
+	f.Close()
+	if err == nil {
+		t.Errorf("Reading with missing transforms did not fail")
+	}
+
+	err = client.RegisterTransform(GzipTransform{}, badNameTransform{})
+	if err == nil {
+		t.Errorf("Registering a transform with an invalid name did not fail")
+	}
+}
+
+type badNameTransform struct{ GzipTransform }
+
+func (badNameTransform) Name() string { return "bad,name" }
+
+func TestTransformedSizes(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addDir("/v1/file/testing")
+	m.addDir("/v1/file/testing/sub")
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.RegisterTransform(GzipTransform{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := client.GetFile("/v1/file/testing/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Repeat("compressible ", 1000)
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	_, err = client.UploadFromReader("big.txt", strings.NewReader(data), int64(len(data)), modified, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := len(m.node("/v1/file/testing/sub/big.txt").data); stored >= len(data) {
+		t.Fatalf("Expected the stored file to be compressed, got %d bytes", stored)
+	}
+
+	// CopyTo uploads the decoded data
+	src, err := client.GetFile("/v1/file/testing/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.CopyTo("/v1/file/testing/copy", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := client.GetFile("/v1/file/testing/copy/big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ioutil.ReadAll(copied)
+	copied.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != data {
+		t.Errorf("Copied file is %d bytes, expected %d", len(result), len(data))
+	}
+
+	// FS reports and serves the decoded size
+	fsys := client.FS("/v1/file/testing/sub")
+	info, err := fs.Stat(fsys, "big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Errorf("FS size is %d, expected %d", info.Size(), len(data))
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = entries[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Errorf("FS directory entry size is %d, expected %d", info.Size(), len(data))
+	}
+	file, err := fsys.Open("big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, err := file.(io.Seeker).Seek(0, io.SeekEnd)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end != int64(len(data)) {
+		t.Errorf("Seek to the end returned %d, expected %d", end, len(data))
+	}
+	rec := httptest.NewRecorder()
+	http.FileServer(http.FS(fsys)).ServeHTTP(rec, httptest.NewRequest("GET", "/big.txt", nil))
+	if rec.Body.String() != data {
+		t.Errorf("File server served %d bytes, expected %d", rec.Body.Len(), len(data))
+	}
+
+	// DownloadDir skips files which already match
+	dir, err := ioutil.TempDir("", "freehold-transform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = src.DownloadDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := filepath.Join(dir, "big.txt")
+	changed := strings.Repeat("X", len(data))
+	err = ioutil.WriteFile(local, []byte(changed), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chtimes(local, modified, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.DownloadDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(local); string(b) != changed {
+		t.Errorf("Matching file was downloaded again")
+	}
+
+	// Sync sees the remote and local files as the same
+	syncDir, err := ioutil.TempDir("", "freehold-transform-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(syncDir)
+
+	_, err = src.Sync(syncDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := src.Sync(syncDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Ops) != 0 {
+		t.Errorf("Expected nothing to sync, got:\n%s", plan)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trashPrefix is the prefix of each user's hidden trash folder and datastore
+const trashPrefix = ".trash-"
+
+// Trash is a user's hidden trash folder.  When UseTrash is set on the client, deleted files
+// and folders are moved into the trash folder, and their original path and deletion time are
+// recorded in the trash datastore, so they can be listed and restored.  Trashed items are made
+// private, and trash folders are skipped by Walk and folder listings, so deleted items don't
+// show up in Sync, DownloadDir, Usage, FS and the like
+type Trash struct {
+	client    *Client
+	folder    string
+	datastore string
+}
+
+// TrashItem is a single deleted file or folder in the trash
+type TrashItem struct {
+	ID string `json:"id"`
+	// Path is the path the file or folder was deleted from
+	Path string `json:"path"`
+	// TrashPath is the path of the file or folder in the trash folder
+	TrashPath string    `json:"trashPath"`
+	IsDir     bool      `json:"isDir,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	Deleted   time.Time `json:"deleted"`
+	// Permissions are the permissions the file or folder had before it was deleted
+	Permissions *Permission `json:"permissions,omitempty"`
+}
+
+// Trash returns the current user's trash.  The trash folder and datastore are created the
+// first time something is deleted
+func (c *Client) Trash() *Trash {
+	return &Trash{
+		client:    c,
+		folder:    "/v1/file/" + trashPrefix + c.username,
+		datastore: "/v1/datastore/" + trashPrefix + c.username + ".ds",
+	}
+}
+
+// trashes is whether or not deleting the property moves it to the trash.  Datastores and
+// files already in a trash folder are always deleted permanently
+func (c *Client) trashes(p *Property) bool {
+	if !c.UseTrash {
+		return false
+	}
+	fhPath, err := ParsePath(p.URL)
+	if err != nil || fhPath.Kind != KindFile || fhPath.App != "" || fhPath.IsRoot() {
+		return false
+	}
+	return !strings.HasPrefix(fhPath.Resource(), "/"+trashPrefix)
+}
+
+// isTrashFolder is whether or not the property is a user's trash folder
+func isTrashFolder(p *Property) bool {
+	if !p.IsDir {
+		return false
+	}
+	fhPath, err := ParsePath(p.URL)
+	if err != nil || fhPath.Kind != KindFile || fhPath.App != "" {
+		return false
+	}
+	return fhPath.Folder == "/" && strings.HasPrefix(fhPath.Name, trashPrefix)
+}
+
+// withoutTrash removes any trash folders from the children of a folder
+func withoutTrash(children []Property) []Property {
+	result := children[:0]
+	for i := range children {
+		if !isTrashFolder(&children[i]) {
+			result = append(result, children[i])
+		}
+	}
+	return result
+}
+
+// List returns the items in the trash, oldest deletion first
+func (t *Trash) List() ([]*TrashItem, error) {
+	ds, err := t.client.GetDatastore(t.datastore)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kvs, err := ds.Iter(&Iter{})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*TrashItem, 0, len(kvs))
+	for _, kv := range kvs {
+		item := &TrashItem{}
+		err = kv.Value(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Deleted.Before(items[j].Deleted)
+	})
+	return items, nil
+}
+
+// Restore moves the item back to the path it was deleted from, creating any missing
+// parent folders, and puts back the item's permissions.  The contents of a restored folder
+// stay private.  Restore fails if something else now exists at that path
+func (t *Trash) Restore(id string) (*File, error) {
+	ds, err := t.client.GetDatastore(t.datastore)
+	if err != nil {
+		return nil, err
+	}
+	item := &TrashItem{}
+	err = ds.Get(id, item)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := t.client.Exists(item.Path)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("Cannot restore %s, a file already exists at that path.", item.Path)
+	}
+
+	err = t.client.MkdirAll(path.Dir(item.Path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.client.doRequest("PUT", item.TrashPath, map[string]string{"move": item.Path}, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = ds.Delete(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Permissions != nil {
+		err = (&Property{URL: item.Path, client: t.client}).SetPermission(item.Permissions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t.client.GetFile(item.Path)
+}
+
+// Empty permanently deletes the items which were deleted more than olderThan ago, or
+// everything in the trash if olderThan is 0.  The deleted items are returned, and items
+// which couldn't be deleted are returned as PathErrors
+func (t *Trash) Empty(olderThan time.Duration) ([]*TrashItem, error) {
+	items, err := t.List()
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	ds, err := t.client.GetDatastore(t.datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	var emptied []*TrashItem
+	var errs PathErrors
+	for _, item := range items {
+		if time.Since(item.Deleted) < olderThan {
+			continue
+		}
+
+		prop, err := t.client.Stat(item.TrashPath)
+		if err == nil {
+			_, err = prop.RemoveAll(nil)
+		}
+		if err != nil && !IsNotFound(err) {
+			errs = append(errs, &PathError{Path: item.TrashPath, Err: err})
+			continue
+		}
+
+		err = ds.Delete(item.ID)
+		if err != nil {
+			errs = append(errs, &PathError{Path: item.TrashPath, Err: err})
+			continue
+		}
+		emptied = append(emptied, item)
+	}
+
+	if len(errs) != 0 {
+		return emptied, errs
+	}
+	return emptied, nil
+}
+
+// put moves the file or folder into the trash and makes it private.  The item is recorded
+// before it's moved, so nothing ends up in the trash without a record of where it came from
+func (t *Trash) put(p *Property) (*TrashItem, error) {
+	err := t.create()
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := time.Now()
+	itemPath := strings.TrimSuffix(p.URL, "/")
+	item := &TrashItem{
+		ID:      deleted.UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix),
+		Path:    itemPath,
+		IsDir:   p.IsDir,
+		Size:    p.Size,
+		Deleted: deleted,
+	}
+	if p.Permissions != nil {
+		item.Permissions = p.Permissions.withoutOwner()
+	}
+	item.TrashPath = t.folder + "/" + item.ID + "-" + path.Base(itemPath)
+
+	ds := &Datastore{Property{URL: t.datastore, client: t.client}}
+	err = ds.Put(item.ID, item)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.client.doRequest("PUT", itemPath, map[string]string{"move": item.TrashPath}, nil)
+	if err != nil {
+		ds.Delete(item.ID)
+		return nil, err
+	}
+
+	err = t.private(item)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// private makes the trashed item, and everything in it, private
+func (t *Trash) private(item *TrashItem) error {
+	if !item.IsDir {
+		return (&Property{URL: item.TrashPath, client: t.client}).SetPermission(PrivatePermission())
+	}
+	return t.client.Walk(item.TrashPath, func(p string, prop *Property, err error) error {
+		if err != nil {
+			return err
+		}
+		return prop.SetPermission(PrivatePermission())
+	})
+}
+
+// create creates the trash folder and datastore if they don't already exist.  They're always
+// created private, regardless of the client's InheritPermissions and PermissionRules
+func (t *Trash) create() error {
+	for _, p := range []string{t.folder, t.datastore} {
+		exists, err := t.client.Exists(p)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		err = t.client.doRequest("POST", p, nil, nil)
+		if err != nil {
+			// another delete may have created it first
+			if exists, _ = t.client.Exists(p); !exists {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/datastore")
+	m.addDir("/v1/file/docs")
+	m.addFile("/v1/file/docs/notes.txt", "notes", modified)
+	m.addDir("/v1/file/docs/sub")
+	m.addFile("/v1/file/docs/sub/report.txt", "report", modified)
+	m.node("/v1/file/docs/sub/report.txt").perm = PublicReadPermission()
+	m.node("/v1/file/docs/notes.txt").perm = PublicReadPermission()
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.UseTrash = true
+	trash := client.Trash()
+
+	items, err := trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("Expected an empty trash got %d items", len(items))
+	}
+
+	report, err := client.GetFile("/v1/file/docs/sub/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = report.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.node("/v1/file/docs/sub/report.txt") != nil {
+		t.Fatalf("Deleted file still exists")
+	}
+
+	docs, err := client.GetFile("/v1/file/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := docs.RemoveAll(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 results got %d", len(results))
+	}
+	if m.node("/v1/file/docs") != nil {
+		t.Fatalf("Deleted folder still exists")
+	}
+
+	items, err = trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items in the trash got %d", len(items))
+	}
+	if items[0].Path != "/v1/file/docs/sub/report.txt" || items[0].IsDir || items[0].Size != 6 {
+		t.Errorf("Incorrect trash item for the deleted file: %+v", items[0])
+	}
+	if items[1].Path != "/v1/file/docs" || !items[1].IsDir {
+		t.Errorf("Incorrect trash item for the deleted folder: %+v", items[1])
+	}
+	if !strings.HasPrefix(items[1].TrashPath, "/v1/file/.trash-"+username+"/") ||
+		m.node(items[1].TrashPath+"/notes.txt") == nil {
+		t.Errorf("Folder wasn't moved into the trash as a whole")
+	}
+	for _, p := range []string{items[0].TrashPath, items[1].TrashPath, items[1].TrashPath + "/notes.txt"} {
+		if prm := m.node(p).perm; prm == nil || prm.Public != "" || prm.Friend != "" {
+			t.Errorf("Trashed item %s was not made private", p)
+		}
+	}
+
+	// the trash isn't part of the file tree
+	err = client.Walk("/v1/file/", func(p string, prop *Property, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.Contains(p, trashPrefix) {
+			t.Errorf("Walk visited the trash: %s", p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := fs.ReadDir(client.FS("/v1/file/"), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected the trash to be hidden from the file system listing got %d entries", len(entries))
+	}
+
+	// the file's parent folders are recreated
+	f, err := trash.Restore(items[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.URL != "/v1/file/docs/sub/report.txt" || m.node("/v1/file/docs/sub/report.txt") == nil {
+		t.Errorf("File was not restored to its original path")
+	}
+	if prm := m.node("/v1/file/docs/sub/report.txt").perm; prm == nil || prm.Public != Read.String() {
+		t.Errorf("Restored file's permissions were not put back")
+	}
+
+	_, err = trash.Restore(items[1].ID)
+	if err == nil {
+		t.Errorf("Restoring over an existing folder didn't fail")
+	}
+	_, err = trash.Restore(items[0].ID)
+	if err == nil {
+		t.Errorf("Restoring an item no longer in the trash didn't fail")
+	}
+
+	// files in the trash are deleted permanently
+	emptied, err := trash.Empty(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emptied) != 0 {
+		t.Errorf("Recently deleted items were emptied from the trash")
+	}
+
+	emptied, err = trash.Empty(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emptied) != 1 || emptied[0].Path != "/v1/file/docs" {
+		t.Fatalf("Expected the folder to be emptied from the trash got %d items", len(emptied))
+	}
+	if m.node(items[1].TrashPath) != nil {
+		t.Errorf("Emptied folder still exists")
+	}
+
+	items, err = trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected an empty trash got %d items", len(items))
+	}
+
+	// names which look percent encoded aren't decoded again
+	m.addFile("/v1/file/100%41.txt", "percent", modified)
+	m.addFile("/v1/file/100A.txt", "unrelated", modified)
+	percent, err := client.GetFile("/v1/file/100%41.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = percent.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.node("/v1/file/100%41.txt") != nil || m.node("/v1/file/100A.txt") == nil {
+		t.Fatalf("The wrong file was moved to the trash")
+	}
+	items, err = trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Path != "/v1/file/100%41.txt" {
+		t.Fatalf("Incorrect trash item for a file with a %% in its name")
+	}
+	_, err = trash.Restore(items[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := m.node("/v1/file/100%41.txt"); n == nil || string(n.data) != "percent" {
+		t.Errorf("File with a %% in its name was not restored")
+	}
+}
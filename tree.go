@@ -0,0 +1,198 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// TreeOptions are the options used by operations which apply to an entire tree of
+// files, folders, or datastores
+type TreeOptions struct {
+	// Concurrency is the number of requests made at the same time, defaults to 4
+	Concurrency int
+	// DryRun returns the paths that would be changed without changing anything
+	DryRun bool
+}
+
+// TreeResult is the outcome of an operation on a single path in a tree
+type TreeResult struct {
+	Path  string `json:"path"`
+	IsDir bool   `json:"isDir,omitempty"`
+	Err   error  `json:"-"`
+
+	prop  *Property
+	depth int
+}
+
+// TreeResults are the outcome of an operation on every path in a tree
+type TreeResults []*TreeResult
+
+// Err returns all of the failures in the results as PathErrors, or nil if
+// every path succeeded
+func (r TreeResults) Err() error {
+	var errs PathErrors
+	for _, res := range r {
+		if res.Err != nil {
+			errs = append(errs, &PathError{Path: res.Path, Err: res.Err})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// RemoveAll deletes the file or datastore, or the folder and everything in it.  The contents
+// of a folder are deleted before the folder itself, and a failure on any one path doesn't
+// stop the rest of the tree from being deleted.  The result for each path is returned, along
+// with any failures as PathErrors.  If the client's UseTrash is set, the file or folder is moved
+// to the trash as a whole instead
+func (p *Property) RemoveAll(opts *TreeOptions) (TreeResults, error) {
+	if opts == nil {
+		opts = &TreeOptions{}
+	}
+
+	results, err := p.tree()
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return results, nil
+	}
+
+	if p.client.trashes(p) {
+		err = p.Delete()
+		for _, r := range results {
+			r.Err = err
+		}
+		return results, results.Err()
+	}
+
+	// delete one level at a time, deepest first, so folders are empty before
+	// they are deleted
+	maxDepth := 0
+	for _, r := range results {
+		if r.depth > maxDepth {
+			maxDepth = r.depth
+		}
+	}
+
+	for depth := maxDepth; depth >= 0; depth-- {
+		var level TreeResults
+		for _, r := range results {
+			if r.depth != depth {
+				continue
+			}
+			if r.IsDir && r.Err == nil && results.failedWithin(r.Path) {
+				r.Err = errors.New("Folder contents could not be deleted")
+			}
+			level = append(level, r)
+		}
+
+		level.run(opts.Concurrency, func(r *TreeResult) error {
+			return r.prop.Delete()
+		})
+	}
+
+	return results, results.Err()
+}
+
+// SetPermissionRecursive sets the permissions of the file or datastore, or the folder and
+// everything in it.  A failure on any one path doesn't stop the rest of the tree from being
+// updated.  The result for each path is returned, along with any failures as PathErrors
+func (p *Property) SetPermissionRecursive(prm *Permission, opts *TreeOptions) (TreeResults, error) {
+	if opts == nil {
+		opts = &TreeOptions{}
+	}
+
+	err := prm.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := p.tree()
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return results, nil
+	}
+
+	results.run(opts.Concurrency, func(r *TreeResult) error {
+		return r.prop.SetPermission(prm)
+	})
+
+	return results, results.Err()
+}
+
+// tree returns a result for every path in the tree rooted at the property.  Folders
+// which can't be listed are marked as failed
+func (p *Property) tree() (TreeResults, error) {
+	root := strings.TrimSuffix(p.URL, "/")
+	var results TreeResults
+
+	err := p.client.Walk(root, func(fp string, prop *Property, err error) error {
+		if prop == nil {
+			return err
+		}
+		if err != nil {
+			results[len(results)-1].Err = err
+			return nil
+		}
+		results = append(results, &TreeResult{
+			Path:  fp,
+			IsDir: prop.IsDir,
+			prop:  prop,
+			depth: strings.Count(strings.TrimPrefix(fp, root), "/"),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// failedWithin is whether or not any path inside of the folder has failed
+func (r TreeResults) failedWithin(dir string) bool {
+	prefix := dir + "/"
+	for _, res := range r {
+		if res.Err != nil && strings.HasPrefix(res.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs fn against every result that hasn't already failed, with the passed in
+// number of requests running at the same time
+func (r TreeResults) run(concurrency int, fn func(*TreeResult) error) {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	work := make(chan *TreeResult)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for res := range work {
+				res.Err = fn(res)
+			}
+		}()
+	}
+
+	for _, res := range r {
+		if res.Err == nil {
+			work <- res
+		}
+	}
+	close(work)
+	wg.Wait()
+}
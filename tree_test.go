@@ -0,0 +1,96 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRemoveAll(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/test.txt", "test file", modified)
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/sub/sub.txt", "sub file", modified)
+	m.addDir("/v1/file/testing/sub/deeper")
+	m.addFile("/v1/file/testing/sub/deeper/deep.txt", "deep file", modified)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := f.RemoveAll(&TreeOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 {
+		t.Errorf("Expected 6 results got %d", len(results))
+	}
+	if m.node("/v1/file/testing/test.txt") == nil {
+		t.Fatalf("Dry run deleted a file")
+	}
+
+	results, err = f.RemoveAll(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 {
+		t.Errorf("Expected 6 results got %d", len(results))
+	}
+
+	m.Lock()
+	remaining := len(m.nodes)
+	m.Unlock()
+	if remaining != 1 {
+		t.Errorf("Expected only the root folder to remain, got %d files", remaining)
+	}
+}
+
+func TestSetPermissionRecursive(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/test.txt", "test file", modified)
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/sub/sub.txt", "sub file", modified)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := client.GetFile(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prm := &Permission{Private: "rw", Friend: "r"}
+	results, err := f.SetPermissionRecursive(prm, &TreeOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Errorf("Expected 4 results got %d", len(results))
+	}
+
+	for _, p := range []string{"/v1/file/testing", "/v1/file/testing/test.txt",
+		"/v1/file/testing/sub", "/v1/file/testing/sub/sub.txt"} {
+		if n := m.node(p); n.perm == nil || n.perm.Friend != "r" {
+			t.Errorf("Permissions were not set on %s", p)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Usage is the disk usage of a folder, and everything in it
+type Usage struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Files   int    `json:"files"`
+	Folders int    `json:"folders"`
+	// Subfolders is the usage of each folder within this one, largest first
+	Subfolders []*Usage `json:"subfolders,omitempty"`
+	// Largest is the largest files in the tree, largest first.  Only set on the root
+	Largest []*UsageFile `json:"largest,omitempty"`
+	// Ages is the number and size of files by how long ago they were last modified.
+	// Only set on the root
+	Ages []*AgeBucket `json:"ages,omitempty"`
+}
+
+// UsageFile is a single file in a usage report
+type UsageFile struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// AgeBucket is the number and total size of files last modified within an age range.
+// MaxAge is zero for the last bucket, which holds everything older than the rest
+type AgeBucket struct {
+	Name   string        `json:"name"`
+	MaxAge time.Duration `json:"maxAge"`
+	Files  int           `json:"files"`
+	Size   int64         `json:"size"`
+}
+
+// UsageOptions are the options used by UsageWithOptions
+type UsageOptions struct {
+	// Depth is how many levels of subfolders are broken down, defaults to 1, which
+	// only breaks down the folders directly in the root
+	Depth int
+	// Largest is the number of largest files reported, defaults to 10
+	Largest int
+	// Concurrency is the number of folders listed in parallel
+	Concurrency int
+}
+
+// usageAges are the age buckets files are grouped into
+var usageAges = []struct {
+	name   string
+	maxAge time.Duration
+}{
+	{"day", 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"year", 365 * 24 * time.Hour},
+	{"older", 0},
+}
+
+// Usage walks the folder and everything in it, and reports the total size and number of files
+// and folders, along with a breakdown by subfolder, the largest files, and the age of files
+func (c *Client) Usage(folderPath string) (*Usage, error) {
+	return c.UsageWithOptions(folderPath, nil)
+}
+
+// UsageWithOptions is the same as Usage, but lets you set how deep the subfolder breakdown goes,
+// how many of the largest files are reported, and how many folders are listed in parallel.
+// A folder which can't be listed doesn't stop the rest of the tree from being counted, and the
+// usage is returned along with any failures as PathErrors
+func (c *Client) UsageWithOptions(folderPath string, opts *UsageOptions) (*Usage, error) {
+	if opts == nil {
+		opts = &UsageOptions{}
+	}
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+	largest := opts.Largest
+	if largest <= 0 {
+		largest = 10
+	}
+
+	root := strings.TrimSuffix(folderPath, "/")
+	now := time.Now()
+
+	usage := &Usage{Path: root}
+	for _, a := range usageAges {
+		usage.Ages = append(usage.Ages, &AgeBucket{Name: a.name, MaxAge: a.maxAge})
+	}
+
+	folders := make(map[string]*Usage)
+	var errs PathErrors
+
+	// ancestors returns the usage of the root, and each broken down folder containing p
+	ancestors := func(p string) []*Usage {
+		result := []*Usage{usage}
+		segments := strings.Split(strings.TrimPrefix(p, root+"/"), "/")
+		for i := 1; i < len(segments) && i <= depth; i++ {
+			dir := root + "/" + strings.Join(segments[:i], "/")
+			if f, ok := folders[dir]; ok {
+				result = append(result, f)
+			}
+		}
+		return result
+	}
+
+	err := c.WalkWithOptions(root, &WalkOptions{Concurrency: opts.Concurrency}, func(p string, prop *Property, err error) error {
+		if err != nil {
+			if prop == nil {
+				return err
+			}
+			errs = append(errs, &PathError{Path: p, Err: err})
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+
+		if prop.IsDir {
+			parents := ancestors(p)
+			for _, u := range parents {
+				u.Folders++
+			}
+			if strings.Count(strings.TrimPrefix(p, root+"/"), "/") < depth {
+				f := &Usage{Path: p}
+				folders[p] = f
+				parent := parents[len(parents)-1]
+				parent.Subfolders = append(parent.Subfolders, f)
+			}
+			return nil
+		}
+
+		for _, u := range ancestors(p) {
+			u.Files++
+			u.Size += prop.Size
+		}
+
+		modified := prop.ModifiedTime()
+		age := now.Sub(modified)
+		for _, b := range usage.Ages {
+			if b.MaxAge == 0 || age < b.MaxAge {
+				b.Files++
+				b.Size += prop.Size
+				break
+			}
+		}
+
+		if len(usage.Largest) < largest || prop.Size > usage.Largest[len(usage.Largest)-1].Size {
+			i := sort.Search(len(usage.Largest), func(i int) bool {
+				return usage.Largest[i].Size < prop.Size
+			})
+			usage.Largest = append(usage.Largest, nil)
+			copy(usage.Largest[i+1:], usage.Largest[i:])
+			usage.Largest[i] = &UsageFile{Path: p, Size: prop.Size, Modified: modified}
+			if len(usage.Largest) > largest {
+				usage.Largest = usage.Largest[:largest]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage.sortSubfolders()
+
+	if len(errs) != 0 {
+		return usage, errs
+	}
+	return usage, nil
+}
+
+func (u *Usage) sortSubfolders() {
+	sort.SliceStable(u.Subfolders, func(i, j int) bool {
+		if u.Subfolders[i].Size != u.Subfolders[j].Size {
+			return u.Subfolders[i].Size > u.Subfolders[j].Size
+		}
+		return u.Subfolders[i].Path < u.Subfolders[j].Path
+	})
+	for _, s := range u.Subfolders {
+		s.sortSubfolders()
+	}
+}
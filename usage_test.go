@@ -0,0 +1,85 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsage(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	now := time.Now()
+	m.addDir("/v1/file/testing")
+	m.addDir("/v1/file/testing/big")
+	m.addDir("/v1/file/testing/big/deeper")
+	m.addDir("/v1/file/testing/small")
+	m.addFile("/v1/file/testing/root.txt", "root", now.Add(-time.Hour))
+	m.addFile("/v1/file/testing/big/a.bin", strings.Repeat("a", 100), now.Add(-3*24*time.Hour))
+	m.addFile("/v1/file/testing/big/deeper/b.bin", strings.Repeat("b", 200), now.Add(-2*365*24*time.Hour))
+	m.addFile("/v1/file/testing/small/c.txt", "c", now.Add(-time.Hour))
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := client.UsageWithOptions(dirPath, &UsageOptions{Largest: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage.Size != 305 || usage.Files != 4 || usage.Folders != 3 {
+		t.Errorf("Totals incorrect. Got size %d files %d folders %d", usage.Size, usage.Files, usage.Folders)
+	}
+
+	if len(usage.Subfolders) != 2 {
+		t.Fatalf("Expected 2 subfolders got %d", len(usage.Subfolders))
+	}
+	big := usage.Subfolders[0]
+	if big.Path != "/v1/file/testing/big" || big.Size != 300 || big.Files != 2 || big.Folders != 1 {
+		t.Errorf("Subfolder usage incorrect. Got %+v", big)
+	}
+	if len(big.Subfolders) != 0 {
+		t.Errorf("Subfolders broken down past the depth")
+	}
+	if usage.Subfolders[1].Path != "/v1/file/testing/small" || usage.Subfolders[1].Size != 1 {
+		t.Errorf("Subfolder usage incorrect. Got %+v", usage.Subfolders[1])
+	}
+
+	if len(usage.Largest) != 2 || usage.Largest[0].Size != 200 || usage.Largest[1].Size != 100 {
+		t.Errorf("Largest files incorrect")
+	}
+
+	ages := map[string]int{}
+	for _, b := range usage.Ages {
+		ages[b.Name] = b.Files
+	}
+	if ages["day"] != 2 || ages["week"] != 1 || ages["older"] != 1 {
+		t.Errorf("Age distribution incorrect. Got %v", ages)
+	}
+
+	usage, err = client.UsageWithOptions(dirPath, &UsageOptions{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Subfolders[0].Subfolders) != 1 || usage.Subfolders[0].Subfolders[0].Size != 200 {
+		t.Errorf("Second level of subfolders incorrect")
+	}
+
+	_, err = json.Marshal(usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Usage("/v1/file/missing")
+	if !IsNotFound(err) {
+		t.Errorf("Expected not found error got %v", err)
+	}
+}
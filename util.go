@@ -4,47 +4,13 @@
 
 package freeholdclient
 
-import (
-	"path"
-	"strings"
-)
-
 var supportedVersions = map[string]struct{}{"v1": struct{}{}}
 
-// splitRootAndPath splits the first item in a path from the rest
-// /v1/file/test.txt:
-// root = "v1"
-// path = "/file/test.txt"
-func splitRootAndPath(pattern string) (root, path string) {
-	if pattern == "" {
-		panic("Invalid pattern")
-	}
-	if pattern[:1] == "/" {
-		pattern = pattern[1:]
-	}
-	split := strings.SplitN(pattern, "/", 2)
-	root = split[0]
-	if len(split) < 2 {
-		path = "/"
-	} else {
-		path = "/" + split[1]
-	}
-	return root, path
-}
-
 func isVersion(version string) bool {
 	_, ok := supportedVersions[version]
 	return ok
 }
 
-func propertyPath(filePath string) string {
-	root, p := splitRootAndPath(filePath)
-	if !isVersion(root) {
-		//root is app
-		ver, p := splitRootAndPath(p)
-		return path.Join(root, ver, "properties", p)
-	}
-
-	return path.Join(root, "properties", p)
-
-}
+// defaultConcurrency is the number of simultaneous requests made by operations
+// that work across a tree of files when no concurrency is specified
+const defaultConcurrency = 4
@@ -0,0 +1,162 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SkipDir is used as a return value from a WalkFunc to indicate that the folder
+// named in the call is to be skipped.  If returned when called on a file, the remaining
+// files and folders in the containing folder are skipped.
+// It is the same value as filepath.SkipDir
+var SkipDir = filepath.SkipDir
+
+// WalkFunc is the function called by Walk for each file, folder, or datastore visited.
+// The path is the full freehold path of the visited item without a trailing slash.
+//
+// If the root can't be retrieved, the function is called once with a nil property and
+// the error.  If a folder's children can't be listed, the function is called a second
+// time for that folder with the error.  Returning a non-nil error other than SkipDir
+// stops the walk and Walk returns that error.
+type WalkFunc func(path string, prop *Property, err error) error
+
+// WalkOptions are the options used by WalkWithOptions
+type WalkOptions struct {
+	// Concurrency is the number of folders listed at the same time while walking
+	// defaults to 4
+	Concurrency int
+	// Sorted visits the children of each folder in name order rather than in the
+	// order returned from the freehold instance
+	Sorted bool
+}
+
+// Walk walks the tree of files or datastores rooted at root, calling fn for each file
+// and folder in the tree including the root.  Folders are visited before their contents,
+// and fn is never called concurrently.  Trash folders are skipped unless they're the root.
+func (c *Client) Walk(root string, fn WalkFunc) error {
+	return c.WalkWithOptions(root, nil, fn)
+}
+
+// WalkWithOptions is the same as Walk, but lets you set how many folders are listed
+// in parallel, and whether or not folders are visited in a deterministic order
+func (c *Client) WalkWithOptions(root string, opts *WalkOptions, fn WalkFunc) error {
+	if opts == nil {
+		opts = &WalkOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	root = strings.TrimSuffix(root, "/")
+
+	f, err := c.GetFile(root)
+	if err != nil {
+		err = fn(root, nil, err)
+		if err == SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	w := &walker{
+		fn:     fn,
+		sorted: opts.Sorted,
+		sem:    make(chan struct{}, concurrency),
+	}
+
+	err = w.walk(root, &f.Property, nil)
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+type walker struct {
+	fn     WalkFunc
+	sorted bool
+	sem    chan struct{}
+}
+
+// listing is the result of a folder's children being retrieved in the background
+type listing struct {
+	done     chan struct{}
+	children []Property
+	err      error
+}
+
+// list starts retrieving the children of the folder in the background, limited by the
+// walker's concurrency
+func (w *walker) list(dir *Property) *listing {
+	l := &listing{done: make(chan struct{})}
+	go func() {
+		w.sem <- struct{}{}
+		l.children, l.err = dir.Children()
+		l.children = withoutTrash(l.children)
+		<-w.sem
+		close(l.done)
+	}()
+	return l
+}
+
+func (w *walker) walk(p string, prop *Property, l *listing) error {
+	err := w.fn(p, prop, nil)
+	if err != nil {
+		if err == SkipDir && prop.IsDir {
+			return nil
+		}
+		return err
+	}
+
+	if !prop.IsDir {
+		return nil
+	}
+
+	if l == nil {
+		l = w.list(prop)
+	}
+	<-l.done
+
+	if l.err != nil {
+		err = w.fn(p, prop, l.err)
+		if err == SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	children := l.children
+	if w.sorted {
+		sort.Sort(propertiesByName(children))
+	}
+
+	listings := make([]*listing, len(children))
+	for i := range children {
+		if children[i].IsDir {
+			listings[i] = w.list(&children[i])
+		}
+	}
+
+	for i := range children {
+		err = w.walk(path.Join(p, children[i].Name), &children[i], listings[i])
+		if err != nil {
+			if err == SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+type propertiesByName []Property
+
+func (p propertiesByName) Len() int           { return len(p) }
+func (p propertiesByName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p propertiesByName) Less(i, j int) bool { return p[i].Name < p[j].Name }
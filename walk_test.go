@@ -0,0 +1,109 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWalk(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 3, 13, 11, 28, 59, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/b.txt", "b", modified)
+	m.addDir("/v1/file/testing/a")
+	m.addFile("/v1/file/testing/a/1.txt", "1", modified)
+	m.addDir("/v1/file/testing/a/sub")
+	m.addFile("/v1/file/testing/a/sub/2.txt", "2", modified)
+	m.addDir("/v1/file/testing/skip")
+	m.addFile("/v1/file/testing/skip/3.txt", "3", modified)
+	m.addFile("/v1/file/testing/c.txt", "c", modified)
+
+	m.addDir("/v1/datastore")
+	m.addDir("/v1/datastore/testdata")
+	m.addFile("/v1/datastore/testdata/test.ds", "", modified)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var visited []string
+	err = client.WalkWithOptions(dirPath, &WalkOptions{Sorted: true}, func(p string, prop *Property, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, p)
+		if p == "/v1/file/testing/skip" {
+			return SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"/v1/file/testing",
+		"/v1/file/testing/a",
+		"/v1/file/testing/a/1.txt",
+		"/v1/file/testing/a/sub",
+		"/v1/file/testing/a/sub/2.txt",
+		"/v1/file/testing/b.txt",
+		"/v1/file/testing/c.txt",
+		"/v1/file/testing/skip",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Walk order does not match. Expected %v got %v", expected, visited)
+	}
+
+	// SkipDir on a file skips the rest of the folder
+	visited = nil
+	err = client.WalkWithOptions(dirPath, &WalkOptions{Sorted: true, Concurrency: 1}, func(p string, prop *Property, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, p)
+		if p == "/v1/file/testing/a/1.txt" {
+			return SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visited) != 7 {
+		t.Errorf("Expected 7 paths visited got %v", visited)
+	}
+
+	visited = nil
+	err = client.Walk("/v1/datastore/", func(p string, prop *Property, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"/v1/datastore", "/v1/datastore/testdata", "/v1/datastore/testdata/test.ds"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Datastore walk does not match. Expected %v got %v", expected, visited)
+	}
+
+	called := false
+	err = client.Walk("/v1/file/missing", func(p string, prop *Property, err error) error {
+		called = true
+		return err
+	})
+	if !called || !IsNotFound(err) {
+		t.Errorf("Expected not found error for missing root got %v", err)
+	}
+}
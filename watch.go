@@ -0,0 +1,325 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often a watched folder is checked for changes if no interval
+// is specified
+const DefaultWatchInterval = 10 * time.Second
+
+// EventOp is the type of change reported by a Watcher
+type EventOp int
+
+// Watch events
+const (
+	Created  EventOp = iota // file or folder was created
+	Modified                // file's size or modified time changed
+	Deleted                 // file or folder was deleted
+	Renamed                 // file was moved from OldPath to Path
+)
+
+func (o EventOp) String() string {
+	switch o {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	case Renamed:
+		return "renamed"
+	}
+	return "unknown"
+}
+
+// Event is a single change to a watched folder
+type Event struct {
+	Op   EventOp
+	Path string
+	// OldPath is the previous path of a renamed file
+	OldPath string
+	// Property is the current properties of the file or folder, or its last known properties
+	// if it was deleted
+	Property *Property
+}
+
+// WatchOptions are the options used by Watch
+type WatchOptions struct {
+	// Recursive watches everything in the folder, instead of only its direct children
+	Recursive bool
+	// Interval is how often the folder is checked for changes, defaults to DefaultWatchInterval
+	Interval time.Duration
+	// Debounce holds back changes to a path until it has stopped changing for at least
+	// this long, so files still being written aren't reported until they are complete
+	Debounce time.Duration
+	// DetectRenames reports a file deleted from one path and created at another, with
+	// the same size and modified time, as a single Renamed event
+	DetectRenames bool
+	// Concurrency is the number of folders listed in parallel when Recursive is set
+	Concurrency int
+}
+
+// Watcher reports changes to a folder on a freehold instance.  Freehold has no change
+// notifications, so the folder is polled, and changes are found by comparing the size and
+// modified time of each file between polls
+type Watcher struct {
+	// Events receives the changes found in each poll
+	Events <-chan Event
+	// Errors receives any errors polling the folder.  Polling continues after an error,
+	// and Errors must be read along with Events or polling will stop
+	Errors <-chan error
+
+	client *Client
+	root   string
+	opts   WatchOptions
+
+	events chan Event
+	errs   chan error
+	done   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	reported map[string]*Property // state last reported through events
+	last     map[string]*Property // state as of the previous poll
+	changed  map[string]time.Time // when each path last changed, until it is reported
+}
+
+// Watch starts watching the folder for changes.  The current contents of the folder are read
+// before Watch returns, and only changes after that are reported.  Close must be called to
+// stop watching
+func (c *Client) Watch(folderPath string, opts *WatchOptions) (*Watcher, error) {
+	if opts == nil {
+		opts = &WatchOptions{}
+	}
+
+	w := &Watcher{
+		client:  c,
+		root:    strings.TrimSuffix(folderPath, "/"),
+		opts:    *opts,
+		events:  make(chan Event),
+		errs:    make(chan error),
+		done:    make(chan struct{}),
+		changed: make(map[string]time.Time),
+	}
+	if w.opts.Interval <= 0 {
+		w.opts.Interval = DefaultWatchInterval
+	}
+	w.Events = w.events
+	w.Errors = w.errs
+
+	root, err := c.GetFile(w.root)
+	if err != nil {
+		return nil, err
+	}
+	if !root.IsDir {
+		return nil, errors.New("Watched path is not a directory.")
+	}
+
+	w.reported, err = w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.last = w.reported
+
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// Close stops the watcher, and closes the Events and Errors channels
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		close(w.events)
+		close(w.errs)
+	})
+	return nil
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		events, err := w.poll(time.Now())
+		if err != nil {
+			select {
+			case w.errs <- err:
+			case <-w.done:
+				return
+			}
+			continue
+		}
+
+		for _, e := range events {
+			select {
+			case w.events <- e:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// snapshot returns the current properties of everything in the watched folder, keyed by path
+func (w *Watcher) snapshot() (map[string]*Property, error) {
+	snap := make(map[string]*Property)
+
+	if !w.opts.Recursive {
+		root, err := w.client.GetFile(w.root)
+		if err != nil {
+			return nil, err
+		}
+		children, err := root.Property.Children()
+		if err != nil {
+			return nil, err
+		}
+		children = withoutTrash(children)
+		for i := range children {
+			snap[strings.TrimSuffix(children[i].URL, "/")] = &children[i]
+		}
+		return snap, nil
+	}
+
+	err := w.client.WalkWithOptions(w.root, &WalkOptions{Concurrency: w.opts.Concurrency},
+		func(p string, prop *Property, err error) error {
+			if err != nil {
+				return err
+			}
+			if p != w.root {
+				snap[p] = prop
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// poll takes a new snapshot and returns the events for every path which has changed since
+// it was last reported, and has been stable for the debounce period
+func (w *Watcher) poll(now time.Time) ([]Event, error) {
+	snap, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p, prop := range snap {
+		if propertyChanged(w.last[p], prop) {
+			w.changed[p] = now
+		}
+	}
+	for p := range w.last {
+		if _, ok := snap[p]; !ok {
+			w.changed[p] = now
+		}
+	}
+	w.last = snap
+
+	var created, deleted, events []Event
+	for p, changed := range w.changed {
+		if now.Sub(changed) < w.opts.Debounce {
+			continue
+		}
+		delete(w.changed, p)
+
+		old, current := w.reported[p], snap[p]
+		switch {
+		case old == nil && current != nil:
+			created = append(created, Event{Op: Created, Path: p, Property: current})
+		case old != nil && current == nil:
+			deleted = append(deleted, Event{Op: Deleted, Path: p, Property: old})
+		case propertyChanged(old, current) && !current.IsDir:
+			events = append(events, Event{Op: Modified, Path: p, Property: current})
+		}
+
+		if current == nil {
+			delete(w.reported, p)
+		} else {
+			w.reported[p] = current
+		}
+	}
+
+	if w.opts.DetectRenames {
+		created, deleted, events = matchRenames(created, deleted, events)
+	}
+
+	events = append(events, deleted...)
+	events = append(events, created...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+	return events, nil
+}
+
+// matchRenames pairs deleted and created files which have the same size and modified time,
+// and which have no other possible match, as renames
+func matchRenames(created, deleted, events []Event) ([]Event, []Event, []Event) {
+	type renameKey struct {
+		size     int64
+		modified string
+	}
+	key := func(prop *Property) renameKey {
+		return renameKey{prop.Size, prop.Modified}
+	}
+
+	byKey := make(map[renameKey][]int)
+	for i, e := range deleted {
+		if !e.Property.IsDir {
+			byKey[key(e.Property)] = append(byKey[key(e.Property)], i)
+		}
+	}
+	createdCount := make(map[renameKey]int)
+	for _, e := range created {
+		if !e.Property.IsDir {
+			createdCount[key(e.Property)]++
+		}
+	}
+
+	matched := make(map[int]bool)
+	var remaining []Event
+	for _, e := range created {
+		k := key(e.Property)
+		if e.Property.IsDir || len(byKey[k]) != 1 || createdCount[k] != 1 {
+			remaining = append(remaining, e)
+			continue
+		}
+		old := byKey[k][0]
+		matched[old] = true
+		events = append(events, Event{Op: Renamed, Path: e.Path, OldPath: deleted[old].Path, Property: e.Property})
+	}
+
+	var remainingDeleted []Event
+	for i, e := range deleted {
+		if !matched[i] {
+			remainingDeleted = append(remainingDeleted, e)
+		}
+	}
+	return remaining, remainingDeleted, events
+}
+
+func propertyChanged(old, current *Property) bool {
+	if old == nil || current == nil {
+		return old != current
+	}
+	return old.IsDir != current.IsDir || old.Size != current.Size || old.Modified != current.Modified
+}
@@ -0,0 +1,155 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	modified := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.addDir("/v1/file/testing")
+	m.addDir("/v1/file/testing/sub")
+	m.addFile("/v1/file/testing/a.txt", "file a", modified)
+	m.addFile("/v1/file/testing/sub/b.txt", "file b", modified)
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := client.Watch(dirPath, &WatchOptions{
+		Recursive:     true,
+		Interval:      time.Hour,
+		Debounce:      time.Minute,
+		DetectRenames: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	now := time.Now()
+	events, err := w.poll(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events got %v", events)
+	}
+
+	m.addFile("/v1/file/testing/new.txt", "new", modified)
+	m.addFile("/v1/file/testing/sub/b.txt", "file b changed", modified)
+	m.Lock()
+	m.nodes["/v1/file/testing/renamed.txt"] = m.nodes["/v1/file/testing/a.txt"]
+	delete(m.nodes, "/v1/file/testing/a.txt")
+	m.Unlock()
+
+	// changes are held back until the debounce period has passed
+	events, err = w.poll(now.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Events were not debounced: %v", events)
+	}
+
+	// the file is still being written
+	m.addFile("/v1/file/testing/new.txt", "new data", modified)
+	events, err = w.poll(now.Add(50 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Events were not debounced: %v", events)
+	}
+
+	events, err = w.poll(now.Add(90 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events got %v", events)
+	}
+	if e := events[0]; e.Op != Renamed || e.Path != "/v1/file/testing/renamed.txt" ||
+		e.OldPath != "/v1/file/testing/a.txt" {
+		t.Errorf("Expected rename event got %+v", e)
+	}
+	if e := events[1]; e.Op != Modified || e.Path != "/v1/file/testing/sub/b.txt" {
+		t.Errorf("Expected modified event got %+v", e)
+	}
+
+	events, err = w.poll(now.Add(3 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Op != Created || events[0].Path != "/v1/file/testing/new.txt" {
+		t.Errorf("Expected created event got %v", events)
+	}
+
+	m.Lock()
+	delete(m.nodes, "/v1/file/testing/new.txt")
+	m.Unlock()
+	events, err = w.poll(now.Add(5 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Events were not debounced: %v", events)
+	}
+	events, err = w.poll(now.Add(7 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Op != Deleted || events[0].Path != "/v1/file/testing/new.txt" {
+		t.Errorf("Expected deleted event got %v", events)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addDir("/v1/file/testing")
+	m.addDir("/v1/file/testing/sub")
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := client.Watch(dirPath, &WatchOptions{Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.addFile("/v1/file/testing/sub/ignored.txt", "not watched", time.Now())
+	m.addFile("/v1/file/testing/a.txt", "file a", time.Now())
+
+	select {
+	case e := <-w.Events:
+		if e.Op != Created || e.Path != "/v1/file/testing/a.txt" || e.Property.Size != 6 {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	case err := <-w.Errors:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+
+	w.Close()
+	if _, ok := <-w.Events; ok {
+		t.Errorf("Events channel was not closed")
+	}
+
+	_, err = client.Watch("/v1/file/missing", nil)
+	if !IsNotFound(err) {
+		t.Errorf("Expected not found watching a missing folder got %v", err)
+	}
+}
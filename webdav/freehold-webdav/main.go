@@ -0,0 +1,86 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+// freehold-webdav serves a freehold instance over a local WebDAV endpoint, so it can
+// be mounted by desktop file managers and office suites.
+//
+// Usage:
+//
+//	freehold-webdav -url https://freeholdinstance.org -user username -token token
+//
+// The password or token can also be passed in with the FREEHOLD_TOKEN environment variable.
+//
+// The WebDAV endpoint has no authentication of its own, and anyone who can reach it can read
+// and change files with your freehold credentials, so only loopback addresses are served unless
+// -allow-remote is passed
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	fh "bitbucket.org/tshannon/freehold-client"
+	"bitbucket.org/tshannon/freehold-client/webdav"
+)
+
+func main() {
+	rootURL := flag.String("url", "", "URL of the freehold instance")
+	user := flag.String("user", "", "Freehold username")
+	token := flag.String("token", os.Getenv("FREEHOLD_TOKEN"), "Freehold password or security token")
+	root := flag.String("root", "/v1/file/", "Freehold folder to serve")
+	addr := flag.String("addr", "localhost:8080", "Local address to serve WebDAV on")
+	tempDir := flag.String("temp", "", "Directory used to buffer uploads, defaults to the system temp dir")
+	allowRemote := flag.Bool("allow-remote", false,
+		"Serve on a non-loopback address, giving anyone who can reach it access to your freehold files")
+	flag.Parse()
+
+	if *rootURL == "" || *user == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if !isLoopback(*addr) {
+		if !*allowRemote {
+			log.Fatalf("Refusing to serve on %s, which isn't a loopback address.  The WebDAV endpoint "+
+				"has no authentication, so anyone who can reach it could read and change your freehold "+
+				"files.  Use an address such as localhost:8080, or pass -allow-remote to serve it anyway", *addr)
+		}
+		log.Printf("WARNING: serving on %s, which isn't a loopback address.  Anyone who can reach it "+
+			"can read and change your freehold files without logging in", *addr)
+	}
+
+	client, err := fh.New(*rootURL, *user, *token)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fsys := webdav.New(client, *root)
+	fsys.TempDir = *tempDir
+
+	handler := fsys.Handler()
+	handler.Logger = func(r *http.Request, err error) {
+		if err != nil {
+			log.Printf("%s %s: %s", r.Method, r.URL.Path, err)
+		}
+	}
+
+	log.Printf("Serving %s%s over WebDAV on http://%s", *rootURL, *root, *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
+
+// isLoopback is whether or not the listen address only accepts connections from this machine
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,256 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+// Package webdav serves the files in a freehold instance over WebDAV, so that
+// desktop file managers and office suites can mount a freehold instance through
+// a local WebDAV endpoint
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	fh "bitbucket.org/tshannon/freehold-client"
+	"golang.org/x/net/webdav"
+)
+
+// FileSystem implements golang.org/x/net/webdav.FileSystem on top of a freehold client
+type FileSystem struct {
+	// TempDir is the directory used to buffer files being written before they are
+	// uploaded to the freehold instance. Defaults to os.TempDir()
+	TempDir string
+
+	client *fh.Client
+	root   string
+	fsys   fs.FS
+}
+
+// New creates a new WebDAV FileSystem serving the freehold folder at root, such as /v1/file/
+func New(client *fh.Client, root string) *FileSystem {
+	root = strings.TrimSuffix(root, "/")
+	return &FileSystem{
+		client: client,
+		root:   root,
+		fsys:   client.FS(root),
+	}
+}
+
+// Handler returns a WebDAV http.Handler serving the file system with an in memory lock system
+func (d *FileSystem) Handler() *webdav.Handler {
+	return &webdav.Handler{
+		FileSystem: d,
+		LockSystem: webdav.NewMemLS(),
+	}
+}
+
+// fullPath returns the freehold path for the WebDAV name
+func (d *FileSystem) fullPath(name string) string {
+	return path.Join(d.root, name)
+}
+
+// fsName returns the io/fs name for the WebDAV name
+func fsName(name string) string {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func davError(err error) error {
+	if fh.IsNotFound(err) {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+// Mkdir creates a new folder
+func (d *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return davError(d.client.NewFolder(d.fullPath(name) + "/"))
+}
+
+// Stat returns the FileInfo of the named file or folder
+func (d *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.Stat(d.fsys, fsName(name))
+}
+
+// Rename moves the file or folder to the new name
+func (d *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	f, err := d.client.GetFile(d.fullPath(oldName))
+	if err != nil {
+		return davError(err)
+	}
+	return davError(f.Move(d.fullPath(newName)))
+}
+
+// RemoveAll deletes the named file, or the folder and everything in it
+func (d *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	if fsName(name) == "." {
+		return os.ErrInvalid
+	}
+
+	f, err := d.client.GetFile(d.fullPath(name))
+	if fh.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = f.RemoveAll(nil)
+	return davError(err)
+}
+
+// OpenFile opens the named file or folder.  Files opened for writing are buffered to a
+// local temp file and uploaded to the freehold instance when closed, as freehold needs
+// to know the size of a file before it's uploaded
+func (d *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		f, err := d.fsys.Open(fsName(name))
+		if err != nil {
+			return nil, err
+		}
+		return &readFile{File: f}, nil
+	}
+
+	existing, err := d.client.GetFile(d.fullPath(name))
+	if err != nil && !fh.IsNotFound(err) {
+		return nil, err
+	}
+	if existing != nil {
+		if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+			return nil, os.ErrExist
+		}
+		if existing.IsDir {
+			return nil, errors.New("Cannot write to a folder.")
+		}
+	} else if flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	tmp, err := ioutil.TempFile(d.TempDir, "freehold-webdav-")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &writeFile{
+		File:     tmp,
+		fs:       d,
+		name:     name,
+		existing: existing,
+	}
+
+	if existing != nil && flag&os.O_TRUNC == 0 {
+		_, err = io.Copy(tmp, existing)
+		existing.Close()
+		if err == nil && flag&os.O_APPEND == 0 {
+			_, err = tmp.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			w.cleanup()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// readFile is a freehold file or folder opened for reading
+type readFile struct {
+	fs.File
+}
+
+func (f *readFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, os.ErrInvalid
+}
+
+func (f *readFile) Readdir(count int) ([]os.FileInfo, error) {
+	dir, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, os.ErrInvalid
+	}
+	entries, err := dir.ReadDir(count)
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, iErr := e.Info()
+		if iErr != nil {
+			return infos, iErr
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
+
+// writeFile is a file opened for writing, buffered in a local temp file until closed
+type writeFile struct {
+	*os.File
+	fs       *FileSystem
+	name     string
+	existing *fh.File
+}
+
+func (f *writeFile) cleanup() {
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
+
+func (f *writeFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *writeFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &namedInfo{info, path.Base(f.name)}, nil
+}
+
+// Close uploads the buffered file to the freehold instance
+func (f *writeFile) Close() error {
+	defer f.cleanup()
+
+	size, err := f.File.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	_, err = f.File.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	if f.existing != nil {
+		return davError(f.existing.Update(f.File, size))
+	}
+
+	fullPath := f.fs.fullPath(f.name)
+	dest, err := f.fs.client.GetFile(path.Dir(fullPath))
+	if err != nil {
+		return davError(err)
+	}
+	_, err = f.fs.client.UploadFromReader(path.Base(fullPath), f.File, size, time.Now(), dest)
+	return davError(err)
+}
+
+// namedInfo reports the name of the WebDAV file rather than the temp file
+type namedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i *namedInfo) Name() string { return i.name }
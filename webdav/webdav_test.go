@@ -0,0 +1,337 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package webdav
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	fh "bitbucket.org/tshannon/freehold-client"
+)
+
+func TestFileSystem(t *testing.T) {
+	uploaded := ""
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/properties/file/testing",
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status":"success","data":{"name":"testing","url":"/v1/file/testing/",
+				"permissions":{"owner":"tshannon","private":"rw"},"modified":"2015-03-06T15:47:40-06:00","isDir":true}}`)
+		})
+	mux.HandleFunc("/v1/properties/file/testing/",
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status":"success","data":[{"name":"test.txt","url":"/v1/file/testing/test.txt",
+				"permissions":{"owner":"tshannon","private":"rw"},"size":9,"modified":"2015-03-13T11:28:59-05:00"}]}`)
+		})
+	mux.HandleFunc("/v1/properties/file/testing/test.txt",
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status":"success","data":{"name":"test.txt","url":"/v1/file/testing/test.txt",
+				"permissions":{"owner":"tshannon","private":"rw"},"size":9,"modified":"2015-03-13T11:28:59-05:00"}}`)
+		})
+	mux.HandleFunc("/v1/properties/file/testing/new.txt",
+		func(w http.ResponseWriter, r *http.Request) {
+			if uploaded == "" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"status":"fail","message":"Not Found"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"status":"success","data":{"name":"new.txt","url":"/v1/file/testing/new.txt",
+				"size":%d,"modified":"2015-03-13T11:28:59-05:00"}}`, len(uploaded))
+		})
+	mux.HandleFunc("/v1/file/testing/test.txt",
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "test file")
+		})
+	mux.HandleFunc("/v1/file/testing",
+		func(w http.ResponseWriter, r *http.Request) {
+			var data []byte
+			file, _, err := r.FormFile("file")
+			if err == nil {
+				data, err = ioutil.ReadAll(file)
+			}
+			if err != nil {
+				// t.Fatal can't be called from the handler's goroutine
+				t.Error(err)
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"status":"fail","message":"Bad Request"}`)
+				return
+			}
+			uploaded = string(data)
+			fmt.Fprint(w, `{"status":"success"}`)
+		})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := fh.New(server.URL, "tester", "testerToken")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	fsys := New(client, "/v1/file/testing/")
+
+	info, err := fsys.Stat(ctx, "/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 9 {
+		t.Errorf("Expected size 9 got %d", info.Size())
+	}
+
+	dir, err := fsys.OpenFile(ctx, "/", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "test.txt" {
+		t.Errorf("Folder contents don't match. Got %v", infos)
+	}
+
+	f, err := fsys.OpenFile(ctx, "/test.txt", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if string(data) != "test file" {
+		t.Errorf("Expected test file got %s", data)
+	}
+
+	_, err = fsys.OpenFile(ctx, "/new.txt", os.O_WRONLY, 0)
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected not exist error opening a missing file without O_CREATE got %v", err)
+	}
+
+	f, err = fsys.OpenFile(ctx, "/new.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Write([]byte("new file data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != "new file data" {
+		t.Errorf("Expected new file data to be uploaded got %s", uploaded)
+	}
+}
+
+// fakeFreehold is a minimal stateful freehold file server, enough for the WebDAV methods
+// which change files
+type fakeFreehold struct {
+	sync.Mutex
+	// files is the data of each file, and nil for folders, keyed by freehold path
+	files map[string][]byte
+}
+
+func (f *fakeFreehold) respond(w http.ResponseWriter, status int, data interface{}) {
+	res := map[string]interface{}{"status": "success", "data": data}
+	if status >= 400 {
+		res = map[string]interface{}{"status": "fail", "message": http.StatusText(status)}
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (f *fakeFreehold) property(p string) map[string]interface{} {
+	prop := map[string]interface{}{
+		"name":     path.Base(p),
+		"url":      p,
+		"modified": "2015-03-13T11:28:59-05:00",
+	}
+	if data := f.files[p]; data == nil {
+		prop["url"] = p + "/"
+		prop["isDir"] = true
+	} else {
+		prop["size"] = len(data)
+	}
+	return prop
+}
+
+func (f *fakeFreehold) children(dir string) []string {
+	var children []string
+	for p := range f.files {
+		if path.Dir(p) == dir && p != dir {
+			children = append(children, p)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+func (f *fakeFreehold) properties(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	p := "/v1" + strings.TrimPrefix(r.URL.Path, "/v1/properties")
+	data, ok := f.files[path.Clean(p)]
+	if !ok {
+		f.respond(w, http.StatusNotFound, nil)
+		return
+	}
+	if data == nil && strings.HasSuffix(p, "/") {
+		children := []map[string]interface{}{}
+		for _, c := range f.children(path.Clean(p)) {
+			children = append(children, f.property(c))
+		}
+		f.respond(w, http.StatusOK, children)
+		return
+	}
+	f.respond(w, http.StatusOK, f.property(path.Clean(p)))
+}
+
+func (f *fakeFreehold) file(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean(r.URL.Path)
+
+	var name string
+	var upload []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err == nil {
+			upload, err = ioutil.ReadAll(file)
+		}
+		if err != nil {
+			f.respond(w, http.StatusBadRequest, nil)
+			return
+		}
+		name = header.Filename
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	data, ok := f.files[p]
+	switch {
+	case r.Method == "GET" && ok && data != nil:
+		w.Write(data)
+	case name != "" && ok && data == nil:
+		_, exists := f.files[path.Join(p, name)]
+		if exists != (r.Method == "PUT") {
+			f.respond(w, http.StatusConflict, nil)
+			return
+		}
+		f.files[path.Join(p, name)] = upload
+		f.respond(w, http.StatusCreated, nil)
+	case r.Method == "POST" && !ok:
+		f.files[p] = nil
+		f.respond(w, http.StatusCreated, nil)
+	case r.Method == "PUT" && ok:
+		input := struct {
+			Move string `json:"move"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&input)
+		if input.Move != "" {
+			for k, v := range f.files {
+				if k == p || strings.HasPrefix(k, p+"/") {
+					delete(f.files, k)
+					f.files[path.Clean(input.Move)+strings.TrimPrefix(k, p)] = v
+				}
+			}
+		}
+		f.respond(w, http.StatusOK, nil)
+	case r.Method == "DELETE" && ok && len(f.children(p)) == 0:
+		delete(f.files, p)
+		f.respond(w, http.StatusOK, nil)
+	default:
+		f.respond(w, http.StatusBadRequest, nil)
+	}
+}
+
+func TestHandlerChanges(t *testing.T) {
+	fake := &fakeFreehold{files: map[string][]byte{
+		"/v1/file":                  nil,
+		"/v1/file/testing":          nil,
+		"/v1/file/testing/test.txt": []byte("test file"),
+	}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/properties/", fake.properties)
+	mux.HandleFunc("/v1/file/", fake.file)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := fh.New(server.URL, "tester", "testerToken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dav := httptest.NewServer(New(client, "/v1/file/testing/").Handler())
+	defer dav.Close()
+
+	request := func(method, name, body string, header map[string]string) int {
+		req, err := http.NewRequest(method, dav.URL+name, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	file := func(p string) ([]byte, bool) {
+		fake.Lock()
+		defer fake.Unlock()
+		data, ok := fake.files[p]
+		return data, ok
+	}
+
+	// overwriting an existing file updates it in place
+	if status := request("PUT", "/test.txt", "overwritten", nil); status >= 300 {
+		t.Fatalf("PUT over an existing file failed with %d", status)
+	}
+	if data, _ := file("/v1/file/testing/test.txt"); string(data) != "overwritten" {
+		t.Errorf("Existing file was not overwritten. Got %s", data)
+	}
+
+	if status := request("MKCOL", "/folder", "", nil); status != http.StatusCreated {
+		t.Fatalf("MKCOL failed with %d", status)
+	}
+	if data, ok := file("/v1/file/testing/folder"); !ok || data != nil {
+		t.Errorf("Folder was not created")
+	}
+
+	if status := request("MOVE", "/test.txt", "", map[string]string{"Destination": dav.URL + "/folder/moved.txt"}); status >= 300 {
+		t.Fatalf("MOVE failed with %d", status)
+	}
+	if _, ok := file("/v1/file/testing/test.txt"); ok {
+		t.Errorf("Moved file still exists at its old name")
+	}
+	if data, _ := file("/v1/file/testing/folder/moved.txt"); string(data) != "overwritten" {
+		t.Errorf("File was not moved. Got %s", data)
+	}
+
+	if status := request("DELETE", "/folder", "", nil); status >= 300 {
+		t.Fatalf("DELETE failed with %d", status)
+	}
+	if _, ok := file("/v1/file/testing/folder"); ok {
+		t.Errorf("Deleted folder still exists")
+	}
+	if _, ok := file("/v1/file/testing/folder/moved.txt"); ok {
+		t.Errorf("Deleted folder's contents still exist")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// SpoolThreshold is the amount of data held in memory by writers returned from Create
+// before the data is spooled to a local temp file
+var SpoolThreshold int64 = 4 << 20
+
+// Create returns a writer for a new or existing file on the freehold instance, which can accept
+// data of an unknown length, such as output from a gzip.Writer or json.Encoder.  Written data is
+// held in memory, or spooled to a temp file once it grows past SpoolThreshold, and uploaded when
+// the writer is closed.  If the file already exists it is overwritten.  If the file is created
+// by someone else between Close checking for it and uploading, the client's OnConflict strategy
+// applies, so with the default ConflictFail, Close returns an *ExistsError.
+// Close must be called for the file to be written, and any errors from the upload are returned
+// from Close
+func (c *Client) Create(filePath string) (io.WriteCloser, error) {
+	fhPath, err := ParsePath(filePath)
+	if err != nil || fhPath.Kind != KindFile || fhPath.IsRoot() || strings.HasSuffix(filePath, "/") {
+		return nil, errors.New("Invalid file path")
+	}
+
+	dest, err := c.GetFile(fhPath.Parent().String())
+	if err != nil {
+		return nil, err
+	}
+	if !dest.IsDir {
+		return nil, errors.New("Destination is not a directory.")
+	}
+
+	return &fileWriter{
+		client:   c,
+		filePath: filePath,
+		dest:     dest,
+		spool:    &spool{threshold: SpoolThreshold},
+	}, nil
+}
+
+type fileWriter struct {
+	client   *Client
+	filePath string
+	dest     *File
+	spool    *spool
+	closed   bool
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("Write to a closed file writer")
+	}
+	return w.spool.Write(p)
+}
+
+// Close uploads the written data to the freehold instance
+func (w *fileWriter) Close() error {
+	if w.closed {
+		return errors.New("File writer already closed")
+	}
+	w.closed = true
+	defer w.spool.cleanup()
+
+	r, err := w.spool.reader()
+	if err != nil {
+		return err
+	}
+
+	existing, err := w.client.GetFile(w.filePath)
+	if err == nil {
+		return existing.Update(r, w.spool.size)
+	}
+	if !IsNotFound(err) {
+		return err
+	}
+
+	_, err = w.client.UploadFromReader(path.Base(w.filePath), r, w.spool.size, time.Now(), w.dest)
+	return err
+}
+
+// spool holds written data in memory until it grows past the threshold, after which
+// it's written to a local temp file
+type spool struct {
+	threshold int64
+	size      int64
+	buf       bytes.Buffer
+	file      *os.File
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && s.size+int64(len(p)) > s.threshold {
+		f, err := ioutil.TempFile("", "freeholdclient-")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		_, err = s.buf.WriteTo(f)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	var err error
+	if s.file != nil {
+		n, err = s.file.Write(p)
+	} else {
+		n, err = s.buf.Write(p)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+// reader returns a reader for all of the data written to the spool
+func (s *spool) reader() (io.Reader, error) {
+	if s.file == nil {
+		return bytes.NewReader(s.buf.Bytes()), nil
+	}
+	_, err := s.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	return s.file, nil
+}
+
+// cleanup removes the spool's temp file if one was created
+func (s *spool) cleanup() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+		s.file = nil
+	}
+	s.buf.Reset()
+}
@@ -0,0 +1,94 @@
+// Copyright 2015 Tim Shannon. All rights reserved.
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package freeholdclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	m := startMockFreehold()
+	defer stopMockServer()
+
+	m.addDir("/v1/file/testing")
+	m.addFile("/v1/file/testing/existing.json", "old", time.Now())
+
+	client, err := New(server.URL, username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Create("/v1/file/missing/new.txt")
+	if !IsNotFound(err) {
+		t.Errorf("Expected not found error when creating in a missing folder got %v", err)
+	}
+
+	// spooled to temp file
+	defer func(threshold int64) { SpoolThreshold = threshold }(SpoolThreshold)
+	SpoolThreshold = 16
+
+	w, err := client.Create("/v1/file/testing/new.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(w)
+	data := strings.Repeat("freehold ", 100)
+	_, err = gz.Write([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gz.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := m.node("/v1/file/testing/new.txt.gz")
+	if n == nil {
+		t.Fatalf("File was not uploaded")
+	}
+	r, err := gzip.NewReader(bytes.NewReader(n.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != data {
+		t.Errorf("Uploaded data doesn't match")
+	}
+
+	// held in memory, overwriting the existing file
+	SpoolThreshold = 1 << 20
+	w, err = client.Create("/v1/file/testing/existing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.NewEncoder(w).Encode(map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m.node("/v1/file/testing/existing.json").data) != `{"key":"value"}`+"\n" {
+		t.Errorf("Existing file was not overwritten. Got %s", m.node("/v1/file/testing/existing.json").data)
+	}
+
+	if w.Close() == nil {
+		t.Errorf("Closing twice did not return an error")
+	}
+}